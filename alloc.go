@@ -0,0 +1,93 @@
+// Copyright 2013 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mscfb
+
+import "encoding/binary"
+
+// Allocation describes how the sectors of a compound file are used. Sectors are numbered as in the FAT, from the sector
+// that follows the header.
+type Allocation struct {
+	SectorSize  int64
+	Last        int64   // the last sector that isn't free in the FAT, or -1
+	Unreachable []int64 // sectors allocated in the FAT, other than to the FAT or DIFAT, that aren't used by the directory, the mini FAT, the mini stream or any stream
+}
+
+// Allocation reads the FAT and locates the sectors of the directory, the mini FAT, the mini stream and each stream to
+// find those that are allocated but unreachable. If the length of the underlying reader is known, the FAT is truncated to
+// the sectors it holds.
+func (r *Reader) Allocation() (*Allocation, error) {
+	ss := int64(r.sectorSize)
+	n := int64(len(r.header.difats))
+	if int64(r.header.numFatSectors) < n {
+		n = int64(r.header.numFatSectors)
+	}
+	nsect := n * ss / 4
+	if r.size >= 0 && (r.size-1)/ss < nsect {
+		nsect = (r.size - 1) / ss // sectors after the header, including any partial sector
+	}
+	fat := make([]uint32, 0, nsect)
+	for _, loc := range r.header.difats[:n] {
+		if int64(len(fat)) >= nsect {
+			break
+		}
+		buf, err := r.readAt(ReasonFAT, (int64(loc)+1)*ss, int(ss))
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < len(buf) && int64(len(fat)) < nsect; i += 4 {
+			fat = append(fat, binary.LittleEndian.Uint32(buf[i:]))
+		}
+	}
+	used := make([]bool, len(fat))
+	mark := func(sn uint32) {
+		if int64(sn) < int64(len(used)) {
+			used[sn] = true
+		}
+	}
+	// follow the directory chain, stopping at cycles
+	for sn, i := r.header.directorySectorLoc, 0; int64(sn) < int64(len(fat)) && i < len(fat); sn, i = fat[sn], i+1 {
+		mark(sn)
+	}
+	for _, sn := range r.header.miniFatLocs {
+		mark(sn)
+	}
+	for _, sn := range r.header.miniStreamLocs {
+		mark(sn)
+	}
+	for _, f := range r.File {
+		if f.objectType != stream || f.Size < miniStreamCutoffSize {
+			continue
+		}
+		ext, err := f.Extents()
+		if err != nil {
+			continue
+		}
+		for _, e := range ext {
+			for off := e.Offset; off < e.Offset+e.Length; off += ss {
+				mark(uint32(off/ss - 1))
+			}
+		}
+	}
+	a := &Allocation{SectorSize: ss, Last: -1}
+	for i, v := range fat {
+		if v != freeSect {
+			a.Last = int64(i)
+		}
+		if v != freeSect && v != fatSect && v != difatSect && !used[i] {
+			a.Unreachable = append(a.Unreachable, int64(i))
+		}
+	}
+	return a, nil
+}
@@ -0,0 +1,52 @@
+package mscfb
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/richardlehane/mscfb/mscfbtest"
+)
+
+func TestAllocation(t *testing.T) {
+	for _, p := range []string{testDoc, testXls, testPpt} {
+		raw, _ := os.ReadFile(p)
+		r, err := New(bytes.NewReader(raw))
+		if err != nil {
+			t.Fatal(err)
+		}
+		a, err := r.Allocation()
+		if err != nil || len(a.Unreachable) != 0 || (a.Last+2)*a.SectorSize < int64(len(raw)) {
+			t.Errorf("%s: bad allocation %+v %v", p, a, err)
+		}
+	}
+	open := func(c ...mscfbtest.Corruption) *Reader {
+		b := mscfbtest.New()
+		b.Stream("WordDocument", make([]byte, 100))
+		b.Stream("Hidden", bytes.Repeat([]byte("payload!"), 1000))
+		doc, err := b.Corrupt(c...).Bytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := New(bytes.NewReader(doc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+	var ext []Extent
+	for _, f := range open().File {
+		if f.Name == "Hidden" {
+			ext, _ = f.Extents()
+		}
+	}
+	if len(ext) == 0 {
+		t.Fatal("expecting extents for Hidden")
+	}
+	last := ext[len(ext)-1]
+	a, err := open(mscfbtest.Orphan("Hidden")).Allocation()
+	if expect := []int64{(last.Offset+last.Length-1)/512 - 1}; err != nil || !reflect.DeepEqual(a.Unreachable, expect) {
+		t.Errorf("expecting unreachable sectors %v, got %+v %v", expect, a, err)
+	}
+}
@@ -0,0 +1,364 @@
+// Copyright 2013 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package analyze reports risk indicators in compound files, in the manner of oleid and olevba.
+//
+// Indicators include VBA macros and their auto-exec entry points, embedded OLE objects and packages, Equation Editor
+// objects, external link monikers, encryption, Flash objects, and data hidden after the last sector or in unreachable sectors.
+// Streams that are themselves compound files are analyzed recursively.
+//
+// Example:
+//
+//	file, _ := os.Open("suspect.doc")
+//	fi, _ := file.Stat()
+//	findings, err := analyze.Analyze(file, fi.Size())
+//	if err != nil {
+//	  log.Fatal(err)
+//	}
+//	for _, f := range findings {
+//	  fmt.Println(f)
+//	}
+package analyze
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/richardlehane/mscfb"
+	"github.com/richardlehane/mscfb/internal/ole"
+	"github.com/richardlehane/mscfb/offcrypto"
+	"github.com/richardlehane/mscfb/oleobj"
+	"github.com/richardlehane/mscfb/vba"
+)
+
+// Severity ranks findings
+type Severity int
+
+const (
+	Info Severity = iota
+	Low
+	Medium
+	High
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Low:
+		return "low"
+	case Medium:
+		return "medium"
+	case High:
+		return "high"
+	}
+	return "info"
+}
+
+// Indicators
+const (
+	VBAMacros      = "vba-macros"
+	AutoExec       = "autoexec"
+	EmbeddedObject = "embedded-object"
+	EquationEditor = "equation-editor"
+	ExternalLink   = "external-link"
+	Encrypted      = "encrypted"
+	Flash          = "flash"
+	NestedFile     = "nested-file"
+	Slack          = "slack"
+	Unreachable    = "unreachable"
+)
+
+// Finding is a risk indicator found in a compound file
+type Finding struct {
+	Indicator   string
+	Severity    Severity
+	Path        string // slash-separated path to the stream or storage; empty for the whole file. Nested compound files are separated by "!/".
+	Description string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s [%s] %s: %s", f.Severity, f.Indicator, f.Path, f.Description)
+}
+
+// Analyze reports the risk indicators in the compound file read from ra. Size is the size of the file and is used to find
+// data hidden after the last sector and in unreachable sectors: if it is 0, those checks are skipped.
+func Analyze(ra io.ReaderAt, size int64) ([]Finding, error) {
+	r, err := mscfb.New(ra)
+	if err != nil {
+		return nil, err
+	}
+	a := &analyzer{}
+	a.analyze(r, size, "")
+	return a.findings, nil
+}
+
+type analyzer struct {
+	findings []Finding
+}
+
+func (a *analyzer) add(ind string, sev Severity, path, format string, args ...interface{}) {
+	a.findings = append(a.findings, Finding{ind, sev, path, fmt.Sprintf(format, args...)})
+}
+
+func join(prefix string, p ...string) string {
+	return prefix + strings.Join(p, "/")
+}
+
+func fullPath(f *mscfb.File) []string {
+	return append(append([]string{}, f.Path...), f.Name)
+}
+
+func (a *analyzer) analyze(r *mscfb.Reader, size int64, prefix string) {
+	a.encryption(r, prefix)
+	a.macros(r, prefix)
+	a.objects(r, prefix)
+	for _, f := range r.File[1:] {
+		path := join(prefix, fullPath(f)...)
+		if f.FileInfo().IsDir() {
+			a.storage(r, f, path)
+			continue
+		}
+		if f.Initial == 1 && f.Name == "Ole" {
+			a.links(f, path)
+		}
+		a.flash(f, path)
+		if f.IsCompound() {
+			nested, err := f.OpenCompound()
+			if err != nil {
+				a.add(NestedFile, Medium, path, "malformed nested compound file: %v", err)
+				continue
+			}
+			a.add(NestedFile, Low, path, "nested compound file (%s)", product(nested.Identify()))
+			a.analyze(nested, f.Size, path+"!/")
+		}
+	}
+	if size > 0 {
+		a.space(r, size, prefix)
+	}
+}
+
+func product(id mscfb.Identity) string {
+	if id.Product != "" {
+		return id.Product
+	}
+	return "unidentified, CLSID " + id.CLSID
+}
+
+func (a *analyzer) encryption(r *mscfb.Reader, prefix string) {
+	s, err := offcrypto.Detect(r)
+	var path string
+	for _, n := range []string{"EncryptedPackage", "WordDocument", "Workbook", "Book", "PowerPoint Document"} {
+		for _, f := range r.File {
+			if len(f.Path) == 0 && f.Name == n {
+				path = join(prefix, n)
+				break
+			}
+		}
+		if path != "" {
+			break
+		}
+	}
+	switch {
+	case err == offcrypto.ErrUnsupported:
+		a.add(Encrypted, Medium, path, "encrypted with an unsupported scheme")
+	case err == nil && s != offcrypto.None:
+		a.add(Encrypted, Medium, path, "encrypted with %s", s)
+	}
+}
+
+// autoexec matches the names of VBA procedures that run automatically
+var autoexec = regexp.MustCompile(`(?i)\b(AutoExec|AutoOpen|Auto_Open|AutoClose|Auto_Close|AutoExit|AutoNew|` +
+	`Document_Open|Document_Close|Document_New|Document_BeforeClose|DocumentOpen|DocumentBeforeClose|NewDocument|` +
+	`Workbook_Open|Workbook_Activate|Workbook_Close|Workbook_BeforeClose|Auto_Activate)\b`)
+
+func (a *analyzer) macros(r *mscfb.Reader, prefix string) {
+	projects, err := vba.Find(r)
+	if err != nil {
+		path := ""
+		if len(projects) > 0 {
+			path = join(prefix, projects[len(projects)-1].Path...)
+		}
+		a.add(VBAMacros, Medium, path, "VBA project could not be parsed: %v", err)
+	}
+	for _, p := range projects {
+		path := join(prefix, p.Path...)
+		a.add(VBAMacros, Medium, path, "VBA project %q with %d modules", p.Name, len(p.Modules))
+		for _, m := range p.Modules {
+			seen := make(map[string]bool)
+			for _, kw := range autoexec.FindAllString(m.Source, -1) {
+				if k := strings.ToLower(kw); !seen[k] {
+					seen[k] = true
+					a.add(AutoExec, High, path+"/"+m.StreamName, "module %s runs %s automatically", m.Name, kw)
+				}
+			}
+		}
+	}
+}
+
+// executable file extensions
+var executable = map[string]bool{
+	".exe": true, ".scr": true, ".com": true, ".pif": true, ".bat": true, ".cmd": true, ".dll": true, ".cpl": true,
+	".js": true, ".jse": true, ".vbs": true, ".vbe": true, ".wsf": true, ".wsh": true, ".hta": true, ".ps1": true,
+	".jar": true, ".lnk": true, ".msi": true, ".reg": true, ".chm": true,
+}
+
+func ext(name string) string {
+	if i := strings.LastIndexByte(name, '.'); i >= 0 {
+		return strings.ToLower(name[i:])
+	}
+	return ""
+}
+
+func (a *analyzer) objects(r *mscfb.Reader, prefix string) {
+	objs, err := oleobj.Find(r)
+	if err != nil {
+		a.add(EmbeddedObject, Medium, prefix, "malformed embedded object: %v", err)
+	}
+	for _, o := range objs {
+		path := join(prefix, o.Path...)
+		switch {
+		case o.Kind != oleobj.Native:
+			a.add(EmbeddedObject, Low, path, "embedded %s stream (%d bytes)", o.Kind, o.Size)
+		case executable[ext(o.Filename)] || executable[ext(o.Source)]:
+			a.add(EmbeddedObject, High, path, "embedded executable %q from %q (%d bytes)", o.Filename, o.Source, o.Size)
+		default:
+			a.add(EmbeddedObject, Medium, path, "embedded file %q from %q (%d bytes)", o.Filename, o.Source, o.Size)
+		}
+	}
+}
+
+var (
+	equationCLSIDs = map[string]bool{
+		"{0002CE02-0000-0000-C000-000000000046}": true, // Equation.3
+		"{0002CE03-0000-0000-C000-000000000046}": true, // Equation.2
+	}
+	flashCLSID = "{D27CDB6E-AE6D-11CF-96B8-444553540000}"
+)
+
+// storage checks a storage's CLSID and CompObj stream for Equation Editor and Flash objects
+func (a *analyzer) storage(r *mscfb.Reader, f *mscfb.File, path string) {
+	id := r.Identify(fullPath(f)...)
+	clsid, progID := id.CLSID, ""
+	if id.CompObj != nil {
+		progID = id.CompObj.ProgID
+		if clsid == "{00000000-0000-0000-0000-000000000000}" {
+			clsid = id.CompObj.CLSID
+		}
+	}
+	switch {
+	case equationCLSIDs[clsid] || strings.HasPrefix(progID, "Equation.2") || strings.HasPrefix(progID, "Equation.3"):
+		a.add(EquationEditor, High, path, "Equation Editor object (%s)", clsid)
+		return
+	case clsid == flashCLSID || strings.HasPrefix(progID, "ShockwaveFlash."):
+		a.add(Flash, High, path, "Shockwave Flash ActiveX object")
+		return
+	}
+	for _, c := range r.File {
+		if c.Name == "Equation Native" && len(c.Path) == len(f.Path)+1 && strings.Join(c.Path, "/") == strings.Join(fullPath(f), "/") {
+			a.add(EquationEditor, High, path, "Equation Native stream")
+			return
+		}
+	}
+	if len(f.Path) > 0 && f.Path[len(f.Path)-1] == "ObjectPool" {
+		a.add(EmbeddedObject, Info, path, "embedded OLE object (%s)", product(id))
+	}
+}
+
+var (
+	urlMoniker  = []byte{0xE0, 0xC9, 0xEA, 0x79, 0xF9, 0xBA, 0xCE, 0x11, 0x8C, 0x82, 0x00, 0xAA, 0x00, 0x4B, 0xA9, 0x0B}
+	fileMoniker = []byte{0x03, 0x03, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xC0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x46}
+)
+
+// links checks an \x01Ole stream (MS-OLEDS 2.3.3) for linked objects and their URL and file monikers
+func (a *analyzer) links(f *mscfb.File, path string) {
+	if f.Size < 8 || f.Size > 1<<20 {
+		return
+	}
+	b, err := ole.ReadAll(f)
+	if err != nil {
+		return
+	}
+	var targets []string
+	for i := bytes.Index(b, urlMoniker); i >= 0; {
+		s := b[i+16:]
+		if len(s) >= 4 {
+			l := int(binary.LittleEndian.Uint32(s))
+			if l <= len(s)-4 {
+				targets = append(targets, ole.UTF16Z(s[4:4+l]))
+			}
+		}
+		j := bytes.Index(s, urlMoniker)
+		if j < 0 {
+			break
+		}
+		i += 16 + j
+	}
+	for i := bytes.Index(b, fileMoniker); i >= 0; {
+		s := b[i+16:]
+		if len(s) >= 6 {
+			l := int(binary.LittleEndian.Uint32(s[2:]))
+			if l <= len(s)-6 {
+				targets = append(targets, strings.TrimRight(string(s[6:6+l]), "\x00"))
+			}
+		}
+		j := bytes.Index(s, fileMoniker)
+		if j < 0 {
+			break
+		}
+		i += 16 + j
+	}
+	for _, t := range targets {
+		a.add(ExternalLink, High, path, "link to %q", t)
+	}
+	if len(targets) == 0 && binary.LittleEndian.Uint32(b[4:])&1 == 1 {
+		a.add(ExternalLink, Medium, path, "linked object")
+	}
+}
+
+// flash scans a stream for SWF headers
+func (a *analyzer) flash(f *mscfb.File, path string) {
+	if f.Size < 8 {
+		return
+	}
+	// read through a section so the caller's file position is untouched
+	sr := io.NewSectionReader(f, 0, f.Size)
+	buf := make([]byte, 1<<16)
+	var base int64 // offset of buf[0] in the stream
+	var carry int
+	for {
+		n, err := io.ReadFull(sr, buf[carry:])
+		b := buf[:carry+n]
+		for i := 0; i+8 <= len(b); i++ {
+			if b[i+1] != 'W' || b[i+2] != 'S' || (b[i] != 'F' && b[i] != 'C' && b[i] != 'Z') {
+				continue
+			}
+			off, ver, l := base+int64(i), b[i+3], int64(binary.LittleEndian.Uint32(b[i+4:]))
+			if ver == 0 || ver > 50 || l < 8 || l > 1<<27 || (b[i] == 'F' && off+l > f.Size) {
+				continue
+			}
+			a.add(Flash, High, path, "SWF file at offset %d (version %d, %d bytes)", off, ver, l)
+			return
+		}
+		if err != nil || len(b) < 8 {
+			return
+		}
+		// keep the last 7 bytes in case a header spans reads
+		carry = 7
+		copy(buf, b[len(b)-carry:])
+		base += int64(len(b) - carry)
+	}
+}
@@ -0,0 +1,118 @@
+package analyze
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/richardlehane/mscfb/internal/oletest"
+	"github.com/richardlehane/mscfb/mscfbtest"
+)
+
+func malicious(t *testing.T) []byte {
+	var dir []byte
+	for _, r := range [][]byte{
+		oletest.Record(0x0003, []byte{0xE4, 0x04}),
+		oletest.Record(0x0004, []byte("Project")),
+		oletest.Record(0x0019, []byte("Module1")),
+		oletest.Record(0x001A, []byte("Module1")),
+		oletest.Record(0x0031, oletest.U32(0)),
+		oletest.Record(0x0021, nil),
+		oletest.Record(0x002B, nil),
+		oletest.Record(0x0010, nil),
+	} {
+		dir = append(dir, r...)
+	}
+	url := utf16.Encode([]rune("http://example.com/x.hta\x00"))
+	ole := append(oletest.U32(0x02000001), oletest.U32(1)...)
+	ole = append(ole, make([]byte, 12)...)
+	ole = append(ole, 0xE0, 0xC9, 0xEA, 0x79, 0xF9, 0xBA, 0xCE, 0x11, 0x8C, 0x82, 0x00, 0xAA, 0x00, 0x4B, 0xA9, 0x0B)
+	ole = append(ole, oletest.U32(uint32(len(url)*2))...)
+	for _, c := range url {
+		ole = append(ole, byte(c), byte(c>>8))
+	}
+	b := mscfbtest.New()
+	b.Stream("WordDocument", make([]byte, 100))
+	b.Storage("Macros").Storage("VBA").
+		Stream("dir", oletest.Compress(dir)).
+		Stream("Module1", oletest.Compress([]byte("Attribute VB_Name = \"Module1\"\r\nSub AutoOpen()\r\n  Shell \"calc\"\r\nEnd Sub\r\n")))
+	pool := b.Storage("ObjectPool")
+	pool.Storage("_1").Stream("\x01Ole10Native", oletest.Native("invoice.exe", "C:\\invoice.exe", []byte("MZ"), ""))
+	pool.Storage("_2").CLSID([16]byte{0x02, 0xCE, 0x02, 0x00, 0, 0, 0, 0, 0xC0, 0, 0, 0, 0, 0, 0, 0x46})
+	pool.Storage("_3").Stream("\x01Ole", ole)
+	b.Stream("Data", append(append(make([]byte, 100), "FWS\x0a"...), append(oletest.U32(20), make([]byte, 20)...)...))
+	doc, err := b.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return append(doc, bytes.Repeat([]byte{0xCC}, 5000)...)
+}
+
+func TestAnalyze(t *testing.T) {
+	doc := malicious(t)
+	findings, err := Analyze(bytes.NewReader(doc), int64(len(doc)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := map[string]Finding{
+		VBAMacros:      {Severity: Medium, Path: "Macros/VBA"},
+		AutoExec:       {Severity: High, Path: "Macros/VBA/Module1"},
+		EmbeddedObject: {Severity: High, Path: "ObjectPool/_1/Ole10Native"},
+		EquationEditor: {Severity: High, Path: "ObjectPool/_2"},
+		ExternalLink:   {Severity: High, Path: "ObjectPool/_3/Ole"},
+		Flash:          {Severity: High, Path: "Data"},
+		Slack:          {Severity: Medium, Path: ""},
+	}
+	got := make(map[string]bool)
+	for _, f := range findings {
+		if e, ok := expect[f.Indicator]; ok && e.Severity == f.Severity && e.Path == f.Path {
+			got[f.Indicator] = true
+		}
+	}
+	for ind := range expect {
+		if !got[ind] {
+			t.Errorf("missing %s finding, got %v", ind, findings)
+		}
+	}
+}
+
+func TestClean(t *testing.T) {
+	for _, path := range []string{"../test/test.doc", "../test/test.xls", "../test/test.ppt", "../test/novpapplan.doc"} {
+		file, _ := os.Open(path)
+		fi, _ := file.Stat()
+		findings, err := Analyze(file, fi.Size())
+		file.Close()
+		if err != nil || len(findings) != 0 {
+			t.Errorf("%s: expecting no findings, got %v %v", path, findings, err)
+		}
+	}
+}
+
+func TestUnreachable(t *testing.T) {
+	for _, v := range []struct {
+		orphans  int
+		severity Severity
+	}{
+		{1, Low},
+		{9, Medium},
+	} {
+		b := mscfbtest.New()
+		b.Stream("WordDocument", make([]byte, 100))
+		b.Stream("Hidden", bytes.Repeat([]byte("payload!"), 2000))
+		for i := 0; i < v.orphans; i++ {
+			b.Corrupt(mscfbtest.Orphan("Hidden"))
+		}
+		doc, err := b.Bytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		findings, err := Analyze(bytes.NewReader(doc), int64(len(doc)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(findings) != 1 || findings[0].Indicator != Unreachable || findings[0].Severity != v.severity {
+			t.Errorf("%d orphaned sectors: expecting an unreachable sectors finding, got %v", v.orphans, findings)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+// Copyright 2013 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package analyze
+
+import "github.com/richardlehane/mscfb"
+
+// space reports data appended after the last allocated sector, and sectors that are allocated in the FAT but not used
+// by the directory, the mini FAT, the mini stream or any stream
+func (a *analyzer) space(r *mscfb.Reader, size int64, prefix string) {
+	al, err := r.Allocation()
+	if err != nil {
+		return
+	}
+	if end := (al.Last + 2) * al.SectorSize; size-end > al.SectorSize {
+		a.add(Slack, Medium, prefix, "%d bytes after the last allocated sector", size-end)
+	}
+	if n := int64(len(al.Unreachable)); n > 0 {
+		sev := Low
+		if n > 8 {
+			sev = Medium
+		}
+		a.add(Unreachable, sev, prefix, "%d allocated sectors (%d bytes) aren't used by any stream", n, n*al.SectorSize)
+	}
+}
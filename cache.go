@@ -0,0 +1,209 @@
+// Copyright 2013 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mscfb
+
+import (
+	"container/list"
+	"io"
+	"sort"
+	"sync"
+)
+
+// CacheOptions configure a Cache. Zero values select the defaults.
+type CacheOptions struct {
+	BlockSize int // size of cached blocks in bytes; default 4096
+	Blocks    int // maximum number of cached blocks; default 1024
+	ReadAhead int // number of blocks to read ahead along a stream's sector chain; default 16, negative to disable
+}
+
+// CacheStats are counts of cache activity
+type CacheStats struct {
+	Hits      int64 // blocks of ReadAt calls found in the cache, including blocks loaded by read-ahead
+	Misses    int64 // blocks of ReadAt calls that had to be loaded from the underlying reader
+	Reads     int64 // calls to the underlying reader's ReadAt
+	BytesRead int64 // bytes read from the underlying reader
+}
+
+// Cache is an io.ReaderAt that caches blocks of a slow underlying reader, such as a network or object storage range
+// reader, in an LRU. Reads of adjacent missing blocks are coalesced into a single read. When a Cache is given to New,
+// File reads also read ahead along the sector chain of the stream being read. New only recognises the *Cache itself: a
+// Cache wrapped in another io.ReaderAt still caches, but doesn't read ahead. A Cache is safe for concurrent use.
+type Cache struct {
+	ra    io.ReaderAt
+	opts  CacheOptions
+	mu    sync.Mutex
+	lru   *list.List // of *block, most recently used at the front
+	index map[int64]*list.Element
+	stats CacheStats
+}
+
+type block struct {
+	n    int64 // block number
+	data []byte
+}
+
+// NewCache returns a Cache reading from ra
+func NewCache(ra io.ReaderAt, opts CacheOptions) *Cache {
+	if opts.BlockSize <= 0 {
+		opts.BlockSize = 4096
+	}
+	if opts.Blocks <= 0 {
+		opts.Blocks = 1024
+	}
+	if opts.ReadAhead == 0 {
+		opts.ReadAhead = 16
+	} else if opts.ReadAhead < 0 {
+		opts.ReadAhead = 0
+	}
+	return &Cache{ra: ra, opts: opts, lru: list.New(), index: make(map[int64]*list.Element)}
+}
+
+// Stats returns the counts of cache activity
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// ReadAt reads from the cache, filling missing blocks from the underlying reader
+func (c *Cache) ReadAt(p []byte, off int64) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	bs := int64(c.opts.BlockSize)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.bypass(off, int64(len(p))) {
+		n, err := c.ra.ReadAt(p, off)
+		c.stats.Reads++
+		c.stats.BytesRead += int64(n)
+		return n, err
+	}
+	if err := c.fill([][2]int64{{off, int64(len(p))}}, true); err != nil {
+		return 0, err
+	}
+	var n int
+	for n < len(p) {
+		pos := off + int64(n)
+		e, ok := c.index[pos/bs]
+		if !ok {
+			return n, io.EOF // past the end of the underlying reader
+		}
+		c.lru.MoveToFront(e)
+		data := e.Value.(*block).data
+		i := int(pos % bs)
+		if i >= len(data) {
+			return n, io.EOF
+		}
+		n += copy(p[n:], data[i:])
+		if len(data) < int(bs) && n < len(p) {
+			return n, io.EOF
+		}
+	}
+	return n, nil
+}
+
+// bypass reports whether a read is larger than the cache, in which case it goes straight to the underlying reader
+func (c *Cache) bypass(off, l int64) bool {
+	bs := int64(c.opts.BlockSize)
+	return (off+l-1)/bs-off/bs >= int64(c.opts.Blocks)
+}
+
+// fetch loads the blocks spanned by a list of offsets and lengths ahead of reading them. Extents that ReadAt would
+// bypass are skipped.
+func (c *Cache) fetch(extents [][2]int64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	keep := make([][2]int64, 0, len(extents))
+	for _, e := range extents {
+		if e[1] > 0 && !c.bypass(e[0], e[1]) {
+			keep = append(keep, e)
+		}
+	}
+	return c.fill(keep, false)
+}
+
+// fill loads missing blocks, reading runs of adjacent missing blocks with a single ReadAt. For demand reads, blocks that
+// are present count as hits and blocks that are missing as misses; read-ahead isn't counted.
+func (c *Cache) fill(extents [][2]int64, demand bool) error {
+	bs := int64(c.opts.BlockSize)
+	var missing []int64
+	seen := make(map[int64]bool)
+	for _, e := range extents {
+		if e[1] <= 0 {
+			continue
+		}
+		for b := e[0] / bs; b <= (e[0]+e[1]-1)/bs; b++ {
+			if el, ok := c.index[b]; ok {
+				if demand {
+					c.stats.Hits++
+				}
+				c.lru.MoveToFront(el)
+				continue
+			}
+			if !seen[b] {
+				seen[b] = true
+				missing = append(missing, b)
+				if demand {
+					c.stats.Misses++
+				}
+			}
+		}
+	}
+	sort.Slice(missing, func(i, j int) bool { return missing[i] < missing[j] })
+	for i := 0; i < len(missing); {
+		j := i + 1
+		for j < len(missing) && missing[j] == missing[j-1]+1 {
+			j++
+		}
+		if err := c.load(missing[i], int64(j-i)); err != nil {
+			return err
+		}
+		i = j
+	}
+	return nil
+}
+
+// load reads a run of blocks with a single ReadAt
+func (c *Cache) load(first, count int64) error {
+	bs := int64(c.opts.BlockSize)
+	buf := make([]byte, count*bs)
+	n, err := c.ra.ReadAt(buf, first*bs)
+	c.stats.Reads++
+	c.stats.BytesRead += int64(n)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	for i := int64(0); i < count && i*bs < int64(n); i++ {
+		end := (i + 1) * bs
+		if end > int64(n) {
+			end = int64(n)
+		}
+		c.add(first+i, buf[i*bs:end:end])
+	}
+	return nil
+}
+
+func (c *Cache) add(n int64, data []byte) {
+	if _, ok := c.index[n]; ok {
+		return
+	}
+	c.index[n] = c.lru.PushFront(&block{n, data})
+	for c.lru.Len() > c.opts.Blocks {
+		e := c.lru.Back()
+		c.lru.Remove(e)
+		delete(c.index, e.Value.(*block).n)
+	}
+}
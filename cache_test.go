@@ -0,0 +1,111 @@
+package mscfb
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// slowReader is a stand-in for a network reader: it counts calls and injects latency
+type slowReader struct {
+	ra    io.ReaderAt
+	calls int64
+}
+
+func (s *slowReader) ReadAt(p []byte, off int64) (int, error) {
+	atomic.AddInt64(&s.calls, 1)
+	time.Sleep(50 * time.Microsecond)
+	return s.ra.ReadAt(p, off)
+}
+
+func readAll(t *testing.T, ra io.ReaderAt) [][]byte {
+	doc, err := New(ra)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ret [][]byte
+	for _, f := range doc.File {
+		b, err := io.ReadAll(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ret = append(ret, b)
+	}
+	return ret
+}
+
+func TestCache(t *testing.T) {
+	raw, _ := os.ReadFile(novPapPlan)
+	direct := &slowReader{ra: bytes.NewReader(raw)}
+	expect := readAll(t, direct)
+	for _, opts := range []CacheOptions{{}, {Blocks: 4, BlockSize: 512, ReadAhead: 2}, {ReadAhead: -1}} {
+		slow := &slowReader{ra: bytes.NewReader(raw)}
+		cache := NewCache(slow, opts)
+		got := readAll(t, cache)
+		if len(got) != len(expect) {
+			t.Fatalf("%+v: expecting %d streams, got %d", opts, len(expect), len(got))
+		}
+		for i := range got {
+			if !bytes.Equal(got[i], expect[i]) {
+				t.Errorf("%+v: stream %d differs", opts, i)
+			}
+		}
+		stats := cache.Stats()
+		if stats.Reads != slow.calls || stats.Hits == 0 || stats.Misses == 0 {
+			t.Errorf("%+v: bad stats %+v (%d calls)", opts, stats, slow.calls)
+		}
+		if slow.calls >= direct.calls || (opts == CacheOptions{} && slow.calls*10 > direct.calls) {
+			t.Errorf("%+v: expecting fewer reads through the cache: %d, against %d", opts, slow.calls, direct.calls)
+		}
+	}
+}
+
+func TestCacheEOF(t *testing.T) {
+	cache := NewCache(bytes.NewReader([]byte("0123456789")), CacheOptions{BlockSize: 4, Blocks: 2})
+	b := make([]byte, 4)
+	if n, err := cache.ReadAt(b, 6); n != 4 || err != nil || string(b) != "6789" {
+		t.Errorf("bad read %d %v %q", n, err, b)
+	}
+	if n, err := cache.ReadAt(b, 8); n != 2 || err != io.EOF || string(b[:n]) != "89" {
+		t.Errorf("expecting a short read at EOF, got %d %v %q", n, err, b[:n])
+	}
+	if n, err := cache.ReadAt(b, 12); n != 0 || err != io.EOF {
+		t.Errorf("expecting EOF, got %d %v", n, err)
+	}
+	// larger than the cache
+	b = make([]byte, 10)
+	if n, err := cache.ReadAt(b, 0); n != 10 || err != nil || string(b) != "0123456789" {
+		t.Errorf("bad read %d %v %q", n, err, b)
+	}
+}
+
+func TestCacheStats(t *testing.T) {
+	slow := &slowReader{ra: bytes.NewReader([]byte("0123456789abcdefghijklmnopqrstuvwxyz"))}
+	cache := NewCache(slow, CacheOptions{BlockSize: 4, Blocks: 4})
+	// read-ahead loads blocks without counting them
+	cache.fetch([][2]int64{{0, 8}})
+	cache.fetch([][2]int64{{4, 8}})
+	if stats := cache.Stats(); stats.Hits != 0 || stats.Misses != 0 || stats.Reads != 2 {
+		t.Errorf("expecting read-ahead to be uncounted, got %+v", stats)
+	}
+	// extents larger than the cache are left to ReadAt
+	cache.fetch([][2]int64{{16, 20}})
+	if slow.calls != 2 {
+		t.Errorf("expecting no read-ahead for an extent larger than the cache, got %d reads", slow.calls)
+	}
+	b := make([]byte, 8)
+	for i := 0; i < 2; i++ {
+		if _, err := cache.ReadAt(b, 4); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := cache.ReadAt(b[:2], 12); err != nil {
+		t.Fatal(err)
+	}
+	if stats := cache.Stats(); stats.Hits != 4 || stats.Misses != 1 || stats.Reads != 3 {
+		t.Errorf("expecting 4 hits and 1 miss, got %+v", stats)
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright 2013 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mscfb
+
+import (
+	"encoding/binary"
+	"strconv"
+	"unicode/utf16"
+
+	"github.com/richardlehane/msoleps/types"
+)
+
+// CompObj is the contents of a \x01CompObj stream, which describes the object held in a storage (https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-oleds/).
+type CompObj struct {
+	CLSID           string
+	UserType        string // display name of the object's type e.g. "Microsoft Word 97-2003 Document"
+	ClipboardFormat string // e.g. "MSWordDoc". Standard clipboard formats are given by name e.g. "CF_METAFILEPICT"
+	ProgID          string // e.g. "Word.Document.8"
+}
+
+const unicodeMarker uint32 = 0x71B239F4
+
+// CompObj decodes this directory entry as a \x01CompObj stream.
+// The Unicode forms of the user type, clipboard format and ProgID are preferred when present.
+// CompObj streams are small: streams held outside the mini stream are an error.
+func (f *File) CompObj() (*CompObj, error) {
+	if f.objectType != stream || f.Size < 28 || f.Size >= miniStreamCutoffSize {
+		return nil, Error{ErrFormat, "bad CompObj stream size", f.Size}
+	}
+	b := make([]byte, f.Size)
+	if n, _ := f.ReadAt(b, 0); n < len(b) {
+		return nil, Error{ErrRead, "CompObj read error", int64(n)}
+	}
+	c := &CompObj{CLSID: types.MustGuid(b[12:28]).String()}
+	d := &compObjDecoder{b: b, off: 28}
+	c.UserType = d.ansi()
+	c.ClipboardFormat = d.clipboard(false)
+	c.ProgID = d.ansi()
+	if d.err != nil {
+		return nil, d.err
+	}
+	// the Unicode part is optional
+	if d.off+4 > len(b) || binary.LittleEndian.Uint32(b[d.off:]) != unicodeMarker {
+		return c, nil
+	}
+	d.off += 4
+	ut, cf, pid := d.unicode(), d.clipboard(true), d.unicode()
+	if d.err != nil {
+		return c, nil
+	}
+	if ut != "" {
+		c.UserType = ut
+	}
+	if cf != "" {
+		c.ClipboardFormat = cf
+	}
+	if pid != "" {
+		c.ProgID = pid
+	}
+	return c, nil
+}
+
+type compObjDecoder struct {
+	b   []byte
+	off int
+	err error
+}
+
+func (d *compObjDecoder) length() int {
+	if d.err != nil {
+		return 0
+	}
+	if d.off+4 > len(d.b) {
+		d.err = Error{ErrFormat, "CompObj stream truncated", int64(d.off)}
+		return 0
+	}
+	l := int(binary.LittleEndian.Uint32(d.b[d.off:]))
+	d.off += 4
+	return l
+}
+
+func (d *compObjDecoder) bytes(l int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if l < 0 || l > len(d.b)-d.off {
+		d.err = Error{ErrFormat, "bad CompObj string length", int64(l)}
+		return nil
+	}
+	ret := d.b[d.off : d.off+l]
+	d.off += l
+	return ret
+}
+
+// ansi reads a length prefixed, null terminated ANSI string
+func (d *compObjDecoder) ansi() string {
+	b := d.bytes(d.length())
+	for i, c := range b {
+		if c == 0 {
+			b = b[:i]
+			break
+		}
+	}
+	return string(b)
+}
+
+// unicode reads a length prefixed, null terminated UTF-16 string. The length is in characters.
+func (d *compObjDecoder) unicode() string {
+	b := d.bytes(d.length() * 2)
+	u := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		c := binary.LittleEndian.Uint16(b[i:])
+		if c == 0 {
+			break
+		}
+		u = append(u, c)
+	}
+	return string(utf16.Decode(u))
+}
+
+// clipboard reads a ClipboardFormatOrAnsiString, or a ClipboardFormatOrUnicodeString
+func (d *compObjDecoder) clipboard(unicode bool) string {
+	if d.err != nil || d.off+4 > len(d.b) {
+		d.length() // sets the truncation error
+		return ""
+	}
+	switch binary.LittleEndian.Uint32(d.b[d.off:]) {
+	case 0:
+		d.off += 4
+		return ""
+	case 0xFFFFFFFF, 0xFFFFFFFE:
+		d.off += 4
+		return clipboardFormat(uint32(d.length()))
+	}
+	if unicode {
+		return d.unicode()
+	}
+	return d.ansi()
+}
+
+func clipboardFormat(cf uint32) string {
+	switch cf {
+	case 1:
+		return "CF_TEXT"
+	case 2:
+		return "CF_BITMAP"
+	case 3:
+		return "CF_METAFILEPICT"
+	case 8:
+		return "CF_DIB"
+	case 13:
+		return "CF_UNICODETEXT"
+	case 14:
+		return "CF_ENHMETAFILE"
+	}
+	return "CF_" + strconv.FormatUint(uint64(cf), 10)
+}
@@ -0,0 +1,351 @@
+// Copyright 2013 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package doc extracts text from Word 97-2003 documents.
+//
+// The text of a document is held in the WordDocument stream as a sequence of pieces, listed by the piece table in
+// the 0Table or 1Table stream. The main document text is followed by the text of footnotes, headers, comments,
+// endnotes and text boxes: these are available as separate ranges.
+//
+// Example:
+//
+//	cfb, _ := mscfb.New(file)
+//	d, err := doc.New(cfb)
+//	if err != nil {
+//	  log.Fatal(err)
+//	}
+//	text, _ := d.Text()
+//	comments, _ := d.Range(doc.Comments)
+package doc
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/richardlehane/mscfb"
+	"github.com/richardlehane/mscfb/internal/ole"
+)
+
+var (
+	// ErrFormat is returned for compound files without a WordDocument stream, and for malformed documents
+	ErrFormat = errors.New("doc: bad Word document")
+	// ErrEncrypted is returned for encrypted or obfuscated documents
+	ErrEncrypted = errors.New("doc: document is encrypted")
+	// ErrVersion is returned for documents written by Word 95 and earlier
+	ErrVersion = errors.New("doc: unsupported Word version")
+)
+
+// Range is a part of the document text
+type Range int
+
+const (
+	Main Range = iota
+	Footnotes
+	Headers
+	Macros
+	Comments
+	Endnotes
+	Textboxes
+	HeaderTextboxes
+)
+
+// FIB is the part of the File Information Block used to locate the text
+type FIB struct {
+	Ident     uint16 // 0xA5EC
+	NFib      uint16 // version of the file format
+	Flags     uint16
+	Lengths   [8]int // character counts of each Range
+	fcClx     uint32
+	lcbClx    uint32
+	whichTbl  int
+	encrypted bool
+}
+
+const (
+	fibIdent     = 0xA5EC
+	fEncrypted   = 0x0100
+	fWhichTblStm = 0x0200
+	fObfuscated  = 0x8000
+	minNFib      = 0x00C1 // Word 97
+	clxIndex     = 33     // index of fcClx/lcbClx in FibRgFcLcb
+)
+
+func parseFIB(b []byte) (FIB, error) {
+	var f FIB
+	if len(b) < 34 {
+		return f, ErrFormat
+	}
+	f.Ident = binary.LittleEndian.Uint16(b)
+	f.NFib = binary.LittleEndian.Uint16(b[2:])
+	f.Flags = binary.LittleEndian.Uint16(b[10:])
+	if f.Ident != fibIdent {
+		return f, ErrFormat
+	}
+	if f.NFib < minNFib {
+		return f, ErrVersion
+	}
+	if f.Flags&fWhichTblStm != 0 {
+		f.whichTbl = 1
+	}
+	f.encrypted = f.Flags&(fEncrypted|fObfuscated) != 0
+	off := 32
+	csw := int(binary.LittleEndian.Uint16(b[off:]))
+	off += 2 + csw*2
+	if off+2 > len(b) {
+		return f, ErrFormat
+	}
+	cslw := int(binary.LittleEndian.Uint16(b[off:]))
+	lw := off + 2
+	if cslw < 11 || lw+cslw*4 > len(b) {
+		return f, ErrFormat
+	}
+	// ccpText to ccpHdrTxbx are the 4th to 11th longs of FibRgLw97
+	for i := range f.Lengths {
+		f.Lengths[i] = int(int32(binary.LittleEndian.Uint32(b[lw+(3+i)*4:])))
+		if f.Lengths[i] < 0 {
+			return f, ErrFormat
+		}
+	}
+	off = lw + cslw*4
+	if off+2 > len(b) {
+		return f, ErrFormat
+	}
+	cb := int(binary.LittleEndian.Uint16(b[off:]))
+	fc := off + 2
+	if cb <= clxIndex || fc+cb*8 > len(b) {
+		return f, ErrFormat
+	}
+	f.fcClx = binary.LittleEndian.Uint32(b[fc+clxIndex*8:])
+	f.lcbClx = binary.LittleEndian.Uint32(b[fc+clxIndex*8+4:])
+	return f, nil
+}
+
+// piece is a run of text in the WordDocument stream
+type piece struct {
+	cpStart, cpEnd int
+	fc             int64 // offset in the WordDocument stream
+	compressed     bool  // 8 bit characters rather than UTF-16
+}
+
+// Document is a Word 97-2003 document
+type Document struct {
+	FIB    FIB
+	word   *mscfb.File
+	pieces []piece
+}
+
+// New reads the FIB and piece table of a Word document
+func New(r *mscfb.Reader) (*Document, error) {
+	var word, table *mscfb.File
+	var tables [2]*mscfb.File
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() || len(f.Path) > 0 {
+			continue
+		}
+		switch f.Name {
+		case "WordDocument":
+			word = f
+		case "0Table":
+			tables[0] = f
+		case "1Table":
+			tables[1] = f
+		}
+	}
+	if word == nil {
+		return nil, ErrFormat
+	}
+	hdr := word.Size
+	if hdr > 4096 {
+		hdr = 4096
+	}
+	b, err := read(word, 0, int(hdr))
+	if err != nil {
+		return nil, err
+	}
+	fib, err := parseFIB(b)
+	if err != nil {
+		return nil, err
+	}
+	if fib.encrypted {
+		return nil, ErrEncrypted
+	}
+	if table = tables[fib.whichTbl]; table == nil {
+		return nil, ErrFormat
+	}
+	if int64(fib.fcClx)+int64(fib.lcbClx) > table.Size {
+		return nil, ErrFormat
+	}
+	clx, err := read(table, int64(fib.fcClx), int(fib.lcbClx))
+	if err != nil {
+		return nil, err
+	}
+	d := &Document{FIB: fib, word: word}
+	if d.pieces, err = pieces(clx); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// pieces parses the piece table from a CLX: any Prc structures are skipped, then the Pcdt holds the PlcPcd
+func pieces(clx []byte) ([]piece, error) {
+	for len(clx) > 0 && clx[0] == 0x01 {
+		if len(clx) < 3 {
+			return nil, ErrFormat
+		}
+		l := 3 + int(int16(binary.LittleEndian.Uint16(clx[1:])))
+		if l < 3 || l > len(clx) {
+			return nil, ErrFormat
+		}
+		clx = clx[l:]
+	}
+	if len(clx) < 5 || clx[0] != 0x02 {
+		return nil, ErrFormat
+	}
+	lcb := int(binary.LittleEndian.Uint32(clx[1:]))
+	plc := clx[5:]
+	if lcb < 4 || lcb > len(plc) || (lcb-4)%12 != 0 {
+		return nil, ErrFormat
+	}
+	n := (lcb - 4) / 12
+	ret := make([]piece, n)
+	for i := range ret {
+		pcd := plc[(n+1)*4+i*8:]
+		fc := binary.LittleEndian.Uint32(pcd[2:])
+		p := piece{
+			cpStart: int(binary.LittleEndian.Uint32(plc[i*4:])),
+			cpEnd:   int(binary.LittleEndian.Uint32(plc[i*4+4:])),
+			fc:      int64(fc),
+		}
+		if fc&0x40000000 != 0 {
+			p.compressed = true
+			p.fc = int64(fc&^0x40000000) / 2
+		}
+		if p.cpEnd < p.cpStart {
+			return nil, ErrFormat
+		}
+		ret[i] = p
+	}
+	return ret, nil
+}
+
+// Range returns the raw text of a range. Paragraphs end with \r, table cells and rows end with \x07, and fields are
+// delimited by \x13 (begin), \x14 (separator) and \x15 (end).
+func (d *Document) Range(r Range) (string, error) {
+	if r < Main || r > HeaderTextboxes {
+		return "", ErrFormat
+	}
+	var start int
+	for i := Main; i < r; i++ {
+		start += d.FIB.Lengths[i]
+	}
+	return d.text(start, start+d.FIB.Lengths[r])
+}
+
+// Text returns the main document text with field instructions removed, paragraph marks as newlines and cell marks
+// as tabs
+func (d *Document) Text() (string, error) {
+	s, err := d.Range(Main)
+	if err != nil {
+		return "", err
+	}
+	return Plain(s), nil
+}
+
+// text returns the characters between two character positions
+func (d *Document) text(start, end int) (string, error) {
+	var sb strings.Builder
+	for _, p := range d.pieces {
+		if p.cpEnd <= start || p.cpStart >= end {
+			continue
+		}
+		s, e := p.cpStart, p.cpEnd
+		if s < start {
+			s = start
+		}
+		if e > end {
+			e = end
+		}
+		sz := int64(2)
+		if p.compressed {
+			sz = 1
+		}
+		b, err := read(d.word, p.fc+int64(s-p.cpStart)*sz, (e-s)*int(sz))
+		if err != nil {
+			return sb.String(), err
+		}
+		if p.compressed {
+			sb.WriteString(ole.Windows1252(b))
+		} else {
+			sb.WriteString(ole.UTF16(b))
+		}
+	}
+	return sb.String(), nil
+}
+
+// Plain converts raw range text to plain text: field instructions are removed, leaving field results; paragraph
+// marks, line breaks and page breaks become newlines; cell marks become tabs; and other control characters are dropped.
+func Plain(s string) string {
+	var (
+		sb    strings.Builder
+		field []bool // for each open field, whether its instructions are being skipped
+	)
+	for _, c := range s {
+		switch c {
+		case 0x13:
+			field = append(field, true)
+			continue
+		case 0x14:
+			if len(field) > 0 {
+				field[len(field)-1] = false
+			}
+			continue
+		case 0x15:
+			if len(field) > 0 {
+				field = field[:len(field)-1]
+			}
+			continue
+		}
+		if len(field) > 0 && field[len(field)-1] {
+			continue
+		}
+		switch {
+		case c == '\r' || c == 0x0B || c == 0x0C:
+			sb.WriteByte('\n')
+		case c == 0x07:
+			sb.WriteByte('\t')
+		case c == '\t' || c >= 0x20:
+			sb.WriteRune(c)
+		}
+	}
+	return sb.String()
+}
+
+func read(f *mscfb.File, off int64, l int) ([]byte, error) {
+	if off < 0 || l < 0 || off+int64(l) > f.Size {
+		return nil, ErrFormat
+	}
+	buf := make([]byte, l)
+	if l == 0 {
+		return buf, nil
+	}
+	if n, err := f.ReadAt(buf, off); n < len(buf) {
+		if err == nil {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return buf, nil
+}
@@ -0,0 +1,122 @@
+package doc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/richardlehane/mscfb"
+	"github.com/richardlehane/mscfb/mscfbtest"
+)
+
+func TestText(t *testing.T) {
+	for path, expect := range map[string][]string{
+		"../test/test.doc":       {"Hurley's \nCOMMON PRACTICE RULES", "most welcome to chris_hurley@ekit.com.\n"},
+		"../test/novpapplan.doc": {"AIM: This research", "‘play the game’ – to sacrifice all"},
+	} {
+		file, _ := os.Open(path)
+		cfb, err := mscfb.New(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, err := New(cfb)
+		if err != nil {
+			t.Fatal(err)
+		}
+		text, err := d.Text()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range expect {
+			if !strings.Contains(text, e) {
+				t.Errorf("%s: expecting text to contain %q", path, e)
+			}
+		}
+		if fn, err := d.Range(Footnotes); err != nil || !strings.HasPrefix(fn, "\x02 ") {
+			t.Errorf("%s: bad footnotes %q %v", path, fn, err)
+		}
+		file.Close()
+	}
+}
+
+// fib builds a minimal Word 97 FIB
+func fib(flags uint16, lengths []int, fcClx, lcbClx int) []byte {
+	b := make([]byte, 32+2+28+2+88+2+93*8)
+	binary.LittleEndian.PutUint16(b, fibIdent)
+	binary.LittleEndian.PutUint16(b[2:], 0xC1)
+	binary.LittleEndian.PutUint16(b[10:], flags)
+	binary.LittleEndian.PutUint16(b[32:], 14)
+	binary.LittleEndian.PutUint16(b[62:], 22)
+	for i, l := range lengths {
+		binary.LittleEndian.PutUint32(b[64+(3+i)*4:], uint32(l))
+	}
+	binary.LittleEndian.PutUint16(b[152:], 93)
+	binary.LittleEndian.PutUint32(b[154+clxIndex*8:], uint32(fcClx))
+	binary.LittleEndian.PutUint32(b[154+clxIndex*8+4:], uint32(lcbClx))
+	return b
+}
+
+func le(v ...interface{}) []byte {
+	buf := &bytes.Buffer{}
+	for _, x := range v {
+		binary.Write(buf, binary.LittleEndian, x)
+	}
+	return buf.Bytes()
+}
+
+func TestPieces(t *testing.T) {
+	// main text "Café €5\r" in a compressed piece then a Unicode piece; a comment "Ω!\r" in the Unicode piece
+	word := fib(fWhichTblStm, []int{8, 0, 0, 0, 3}, 0, 0)
+	compressed := len(word)
+	word = append(word, "Caf\xe9 \x80"...)
+	unicode := len(word)
+	word = append(word, '5', 0, '\r', 0, 0xA9, 0x03, '!', 0, '\r', 0)
+	// a Prc to skip, then the Pcdt: three CPs and two PCDs
+	plc := le(uint32(0), uint32(6), uint32(11),
+		uint16(0), uint32(compressed*2)|0x40000000, uint16(0),
+		uint16(0), uint32(unicode), uint16(0))
+	clx := append([]byte{0x01, 2, 0, 0xAA, 0xBB, 0x02}, le(uint32(len(plc)))...)
+	clx = append(clx, plc...)
+	copy(word, fib(fWhichTblStm, []int{8, 0, 0, 0, 3}, 10, len(clx)))
+	b := mscfbtest.New()
+	b.Stream("WordDocument", word)
+	b.Stream("1Table", append(make([]byte, 10), clx...))
+	buf, err := b.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfb, err := mscfb.New(bytes.NewReader(buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := New(cfb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, err := d.Range(Main); s != "Café €5\r" || err != nil {
+		t.Errorf("bad main text %q %v", s, err)
+	}
+	if s, err := d.Range(Comments); s != "Ω!\r" || err != nil {
+		t.Errorf("bad comments %q %v", s, err)
+	}
+}
+
+func TestEncrypted(t *testing.T) {
+	b := mscfbtest.New()
+	b.Stream("WordDocument", fib(fEncrypted, nil, 0, 0))
+	b.Stream("0Table", make([]byte, 10))
+	buf, _ := b.Bytes()
+	cfb, _ := mscfb.New(bytes.NewReader(buf))
+	if _, err := New(cfb); err != ErrEncrypted {
+		t.Errorf("expecting ErrEncrypted, got %v", err)
+	}
+}
+
+func TestPlain(t *testing.T) {
+	in := "See \x13 HYPERLINK \"x\" \x14\x13 REF a \x14here\x15\x15.\rA\x07B\x07\x07\x01end"
+	if out := Plain(in); out != "See here.\nA\tB\t\tend" {
+		t.Errorf("bad plain text %q", out)
+	}
+}
@@ -79,7 +79,7 @@ func (r *Reader) setDirEntries() error {
 	num := int(r.sectorSize / 128)
 	sn := r.header.directorySectorLoc
 	for sn != endOfChain {
-		buf, err := r.readAt(fileOffset(r.sectorSize, sn), int(r.sectorSize))
+		buf, err := r.readAt(ReasonDirectory, fileOffset(r.sectorSize, sn), int(r.sectorSize))
 		if err != nil {
 			return Error{ErrRead, "directory entries read error (" + err.Error() + ")", fileOffset(r.sectorSize, sn)}
 		}
@@ -248,6 +248,9 @@ func (f *File) Read(b []byte) (int, error) {
 	if err != nil {
 		return 0, err
 	}
+	if f.r.cache != nil {
+		f.readAhead(str, sz)
+	}
 	// now read
 	var idx, i int
 	for _, v := range str {
@@ -255,7 +258,7 @@ func (f *File) Read(b []byte) (int, error) {
 		if jdx < idx || jdx > sz {
 			return 0, Error{ErrRead, "bad read length", int64(jdx)}
 		}
-		j, err := f.r.ra.ReadAt(b[idx:jdx], v[0])
+		j, err := f.r.read(f.reason(), b[idx:jdx], v[0])
 		i = i + j
 		if err != nil {
 			f.i += int64(i)
@@ -272,6 +275,92 @@ func (f *File) Read(b []byte) (int, error) {
 	return i, err
 }
 
+// WriteTo writes the remainder of this stream, from the current position, to w. It implements io.WriterTo, so io.Copy
+// uses it in place of Read. Runs of contiguous sectors are written in a single write; if the underlying reader is a
+// Slicer, they are written without copying.
+func (f *File) WriteTo(w io.Writer) (int64, error) {
+	if f.Size < 1 || f.i >= f.Size {
+		return 0, nil
+	}
+	if err := f.checkSize(); err != nil {
+		return 0, err
+	}
+	start := f.i
+	c := *f
+	locs, err := c.stream(int(f.Size - f.i))
+	if err != nil {
+		return 0, err
+	}
+	var n int64
+	var buf []byte
+	if !f.r.slicer {
+		sz := f.Size - f.i
+		if sz > 1<<20 {
+			sz = 1 << 20
+		}
+		buf = make([]byte, sz)
+	}
+	for _, v := range locs {
+		for off, end := v[0], v[0]+v[1]; off < end && err == nil; {
+			var b []byte
+			if f.r.slicer {
+				if b, err = f.r.slice(f.reason(), off, int(end-off)); err != nil {
+					err = Error{ErrRead, "slicer read error (" + err.Error() + ")", off}
+					break
+				}
+			} else {
+				b = buf
+				if int64(len(b)) > end-off {
+					b = b[:end-off]
+				}
+				if j, rerr := f.r.read(f.reason(), b, off); j < len(b) {
+					if rerr == nil {
+						rerr = io.ErrUnexpectedEOF
+					}
+					err = Error{ErrRead, "underlying reader fail (" + rerr.Error() + ")", off}
+					break
+				}
+			}
+			var j int
+			j, err = w.Write(b)
+			if err == nil && j < len(b) {
+				err = io.ErrShortWrite
+			}
+			n += int64(j)
+			off += int64(len(b))
+		}
+		if err != nil {
+			break
+		}
+	}
+	// leave the position after the bytes written
+	if start+n == f.Size {
+		f.i, f.rem, f.curSector = f.Size, c.rem, c.curSector
+		return n, err
+	}
+	if _, serr := f.Seek(start+n, 0); serr != nil {
+		return n, serr
+	}
+	return n, err
+}
+
+// readAhead loads the sectors for a read of sz bytes at locs, and the sectors that follow in the chain, into the cache
+// with coalesced reads. It is called once f.stream has returned locs, so the chain is continued rather than walked again.
+// Locs too large for the cache are skipped, as they will be read directly.
+func (f *File) readAhead(locs [][2]int64, sz int) {
+	ahead := int64(f.r.cache.opts.ReadAhead * f.r.cache.opts.BlockSize)
+	if ahead > f.Size-f.i-int64(sz) {
+		ahead = f.Size - f.i - int64(sz)
+	}
+	if ahead > 0 {
+		c := *f
+		if more, err := c.stream(int(ahead)); err == nil {
+			locs = append(locs[:len(locs):len(locs)], more...)
+		}
+	}
+	f.r.cache.fetch(locs)
+}
+
 // Write to this directory entry
 // Depends on the io.ReaderAt supplied to mscfb.New() being a WriterAt too
 // Returns 0, io.EOF if no stream is available (i.e. for a storage object)
@@ -302,7 +391,7 @@ func (f *File) Write(b []byte) (int, error) {
 		if jdx < idx || jdx > sz {
 			return 0, Error{ErrWrite, "bad write length", int64(jdx)}
 		}
-		j, err := f.r.wa.WriteAt(b[idx:jdx], v[0])
+		j, err := f.r.write(f.reason(), b[idx:jdx], v[0])
 		i = i + j
 		if err != nil {
 			f.i += int64(i)
@@ -434,6 +523,62 @@ func (f *File) seek(sz int64) error {
 	}
 }
 
+// limit returns the most bytes a stream can hold: the mini stream's size for mini streams, otherwise the length of the
+// underlying reader or, if that isn't known, the sectors addressed by the FAT
+func (r *Reader) limit(mini bool) int64 {
+	ss := int64(r.sectorSize)
+	if mini {
+		return int64(len(r.header.miniStreamLocs)) * ss
+	}
+	n := int64(len(r.header.difats))
+	if fs := int64(r.header.numFatSectors); fs < n {
+		n = fs
+	}
+	n *= ss / 4 * ss
+	if r.size >= 0 && r.size < n {
+		n = r.size
+	}
+	return n
+}
+
+// checkSize returns an error if the size in the directory entry is negative or larger than the stream could be
+func (f *File) checkSize() error {
+	if f.Size < 0 || f.Size > f.r.limit(f.Size < miniStreamCutoffSize) {
+		return Error{ErrRead, "stream size exceeds the compound file", f.Size}
+	}
+	return nil
+}
+
+// Extent is a run of contiguous bytes in the underlying reader
+type Extent struct {
+	Offset int64
+	Length int64
+}
+
+// Extents returns the runs of contiguous sectors, in the underlying reader, that hold this stream, in stream order.
+// Adjacent sectors are coalesced. Streams smaller than 4096 bytes are held in the mini stream, so their extents are
+// runs of 64 byte mini sectors. Storage objects and empty streams have no extents. Sizes larger than the compound file
+// could hold are an error.
+func (f *File) Extents() ([]Extent, error) {
+	if f.objectType != stream || f.Size == 0 {
+		return nil, nil
+	}
+	if err := f.checkSize(); err != nil {
+		return nil, err
+	}
+	c := *f
+	c.i, c.rem, c.curSector = 0, 0, f.startingSectorLoc
+	locs, err := c.stream(int(f.Size))
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]Extent, len(locs))
+	for i, l := range locs {
+		ret[i] = Extent{l[0], l[1]}
+	}
+	return ret, nil
+}
+
 // return offsets and lengths for read or write
 func (f *File) stream(sz int) ([][2]int64, error) {
 	// calculate ministream, cap for sector slice, and sector size
@@ -1,6 +1,11 @@
 package mscfb
 
-import "testing"
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+)
 
 func equal(a [][2]int64, b [][2]int64) bool {
 	if len(a) != len(b) {
@@ -28,3 +33,132 @@ func TestCompress(t *testing.T) {
 		t.Errorf("Streams compress fail; Expecting: %v, Got: %v", br, b)
 	}
 }
+
+// sliceReader is a Slicer over a byte slice
+type sliceReader struct{ *bytes.Reader }
+
+func (s sliceReader) Slice(offset int64, length int) ([]byte, error) {
+	b := make([]byte, length)
+	_, err := s.ReadAt(b, offset)
+	return b, err
+}
+
+func TestExtents(t *testing.T) {
+	raw, _ := os.ReadFile(novPapPlan)
+	doc, err := New(sliceReader{bytes.NewReader(raw)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range doc.File {
+		ext, err := f.Extents()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.FileInfo().IsDir() {
+			if len(ext) != 0 {
+				t.Errorf("%s: expecting no extents for a storage", f.Name)
+			}
+			continue
+		}
+		var b []byte
+		for i, e := range ext {
+			if i > 0 && ext[i-1].Offset+ext[i-1].Length == e.Offset {
+				t.Errorf("%s: extents %d and %d are contiguous", f.Name, i-1, i)
+			}
+			b = append(b, raw[e.Offset:e.Offset+e.Length]...)
+		}
+		expect, _ := f.Bytes()
+		if !bytes.Equal(b, expect) {
+			t.Errorf("%s: extents don't match stream contents", f.Name)
+		}
+	}
+}
+
+type limitWriter struct {
+	buf bytes.Buffer
+	max int
+}
+
+func (l *limitWriter) Write(b []byte) (int, error) {
+	if l.buf.Len()+len(b) > l.max {
+		n, _ := l.buf.Write(b[:l.max-l.buf.Len()])
+		return n, io.ErrShortWrite
+	}
+	return l.buf.Write(b)
+}
+
+// endWriter takes every byte but fails the write that reaches end
+type endWriter struct {
+	buf bytes.Buffer
+	end int
+}
+
+func (e *endWriter) Write(b []byte) (int, error) {
+	n, _ := e.buf.Write(b)
+	if e.buf.Len() >= e.end {
+		return n, io.ErrClosedPipe
+	}
+	return n, nil
+}
+
+func TestOversize(t *testing.T) {
+	for _, n := range []uint64{1 << 62, 1 << 63, 1 << 30} {
+		for _, f := range oversized(t, n) {
+			if _, err := f.Extents(); err == nil {
+				t.Errorf("%d: expecting an error from Extents", n)
+			}
+			if f.Size < 0 {
+				continue // like Read, WriteTo treats the stream as empty
+			}
+			if _, err := f.WriteTo(io.Discard); err == nil {
+				t.Errorf("%d: expecting an error from WriteTo", n)
+			}
+			if _, err := f.Read(make([]byte, 100)); err != nil {
+				t.Errorf("%d: expecting Read to succeed, got %v", n, err)
+			}
+		}
+	}
+}
+
+func TestWriteTo(t *testing.T) {
+	raw, _ := os.ReadFile(testDoc)
+	for _, ra := range []io.ReaderAt{bytes.NewReader(raw), sliceReader{bytes.NewReader(raw)}} {
+		doc, err := New(ra)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cmp, _ := New(bytes.NewReader(raw))
+		for i, f := range doc.File {
+			expect, _ := io.ReadAll(cmp.File[i])
+			var buf bytes.Buffer
+			if n, err := io.Copy(&buf, f); err != nil || n != int64(len(expect)) || !bytes.Equal(buf.Bytes(), expect) {
+				t.Errorf("%s: bad WriteTo %d %v", f.Name, n, err)
+			}
+			if n, err := f.Read(make([]byte, 1)); n != 0 || err != io.EOF {
+				t.Errorf("%s: expecting EOF after WriteTo, got %d %v", f.Name, n, err)
+			}
+			if f.Size < 5000 {
+				continue
+			}
+			// from an offset, with a writer that fails part way
+			f.Seek(1000, 0)
+			lw := &limitWriter{max: 3000}
+			if n, err := f.WriteTo(lw); n != 3000 || err != io.ErrShortWrite || !bytes.Equal(lw.buf.Bytes(), expect[1000:4000]) {
+				t.Errorf("%s: bad partial WriteTo %d %v", f.Name, n, err)
+			}
+			b := make([]byte, 10)
+			if _, err := f.Read(b); err != nil || !bytes.Equal(b, expect[4000:4010]) {
+				t.Errorf("%s: bad position after partial WriteTo (%v)", f.Name, err)
+			}
+			// with a writer that fails after taking the last byte
+			f.Seek(1000, 0)
+			ew := &endWriter{end: int(f.Size) - 1000}
+			if n, err := f.WriteTo(ew); n != f.Size-1000 || err != io.ErrClosedPipe || !bytes.Equal(ew.buf.Bytes(), expect[1000:]) {
+				t.Errorf("%s: bad failed WriteTo %d %v", f.Name, n, err)
+			}
+			if n, err := f.Read(b); n != 0 || err != io.EOF {
+				t.Errorf("%s: expecting EOF after failed WriteTo, got %d %v", f.Name, n, err)
+			}
+		}
+	}
+}
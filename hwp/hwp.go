@@ -0,0 +1,268 @@
+// Copyright 2013 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package hwp reads Hangul Word Processor (HWP 5.0) documents.
+//
+// The FileHeader stream holds the signature, version and properties of a document. The text is in the BodyText
+// storage, in a stream per section (Section0, Section1 ...), as a sequence of tagged records. Section streams are
+// compressed with raw DEFLATE if the compressed flag is set.
+//
+// Example:
+//
+//	cfb, _ := mscfb.New(file)
+//	d, err := hwp.New(cfb)
+//	if err != nil {
+//	  log.Fatal(err)
+//	}
+//	text, _ := d.Text()
+package hwp
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/richardlehane/mscfb"
+	"github.com/richardlehane/mscfb/internal/ole"
+)
+
+var (
+	// ErrFormat is returned for compound files that aren't HWP 5.0 documents, and for malformed documents
+	ErrFormat = errors.New("hwp: bad HWP document")
+	// ErrEncrypted is returned for password protected, DRM and distribution documents
+	ErrEncrypted = errors.New("hwp: document is encrypted")
+)
+
+const signature = "HWP Document File"
+
+// FileHeader properties
+const (
+	FlagCompressed   = 0x01
+	FlagPassword     = 0x02
+	FlagDistribution = 0x04
+	FlagScript       = 0x08
+	FlagDRM          = 0x10
+)
+
+// Version is a document version: major, minor, build and revision numbers in the high to low bytes
+type Version uint32
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d.%d", v>>24, v>>16&0xFF, v>>8&0xFF, v&0xFF)
+}
+
+// FileHeader is the FileHeader stream
+type FileHeader struct {
+	Signature string
+	Version   Version
+	Flags     uint32
+}
+
+// Compressed reports whether section streams are compressed
+func (h FileHeader) Compressed() bool { return h.Flags&FlagCompressed != 0 }
+
+// Encrypted reports whether the document is password protected, DRM protected or a distribution document
+func (h FileHeader) Encrypted() bool {
+	return h.Flags&(FlagPassword|FlagDistribution|FlagDRM) != 0
+}
+
+// Document is an HWP 5.0 document
+type Document struct {
+	Header   FileHeader
+	sections []*mscfb.File
+}
+
+// New reads the FileHeader of a document and locates its sections
+func New(r *mscfb.Reader) (*Document, error) {
+	d := &Document{}
+	var hdr *mscfb.File
+	nums := make(map[*mscfb.File]int)
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		switch {
+		case len(f.Path) == 0 && f.Name == "FileHeader":
+			hdr = f
+		case len(f.Path) == 1 && f.Path[0] == "BodyText" && strings.HasPrefix(f.Name, "Section"):
+			n, err := strconv.Atoi(strings.TrimPrefix(f.Name, "Section"))
+			if err != nil {
+				continue
+			}
+			nums[f] = n
+			d.sections = append(d.sections, f)
+		}
+	}
+	if hdr == nil {
+		return nil, ErrFormat
+	}
+	buf := make([]byte, 40)
+	if n, err := hdr.ReadAt(buf, 0); n < len(buf) {
+		if err == nil || err == io.EOF {
+			err = ErrFormat
+		}
+		return nil, err
+	}
+	d.Header = FileHeader{
+		Signature: strings.TrimRight(string(buf[:32]), "\x00"),
+		Version:   Version(binary.LittleEndian.Uint32(buf[32:])),
+		Flags:     binary.LittleEndian.Uint32(buf[36:]),
+	}
+	if d.Header.Signature != signature || d.Header.Version>>24 != 5 {
+		return nil, ErrFormat
+	}
+	if d.Header.Encrypted() {
+		return nil, ErrEncrypted
+	}
+	sort.Slice(d.sections, func(i, j int) bool { return nums[d.sections[i]] < nums[d.sections[j]] })
+	return d, nil
+}
+
+// Sections returns the number of sections
+func (d *Document) Sections() int {
+	return len(d.sections)
+}
+
+// Section returns the decompressed stream of a section
+func (d *Document) Section(i int) (io.Reader, error) {
+	if i < 0 || i >= len(d.sections) {
+		return nil, ErrFormat
+	}
+	r := io.NewSectionReader(d.sections[i], 0, d.sections[i].Size)
+	if !d.Header.Compressed() {
+		return r, nil
+	}
+	return flate.NewReader(r), nil
+}
+
+// Records returns a Reader for the records of a section
+func (d *Document) Records(i int) (*Reader, error) {
+	s, err := d.Section(i)
+	if err != nil {
+		return nil, err
+	}
+	return NewReader(s), nil
+}
+
+// Text returns the text of all sections, with a newline after each paragraph
+func (d *Document) Text() (string, error) {
+	var sb strings.Builder
+	for i := range d.sections {
+		rr, err := d.Records(i)
+		if err != nil {
+			return sb.String(), err
+		}
+		for {
+			rec, err := rr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return sb.String(), err
+			}
+			if rec.Tag == TagParaText {
+				sb.WriteString(ParaText(rec.Data))
+				sb.WriteByte('\n')
+			}
+		}
+	}
+	return sb.String(), nil
+}
+
+// ParaText decodes the data of a PARA_TEXT record. Controls are dropped, except for tabs, line breaks and special
+// spaces and hyphens; the paragraph break that ends the text is removed.
+func ParaText(b []byte) string {
+	u := make([]byte, 0, len(b))
+	for i := 0; i+1 < len(b); i += 2 {
+		c := binary.LittleEndian.Uint16(b[i:])
+		switch {
+		case c >= 32:
+			u = append(u, b[i], b[i+1])
+			continue
+		case c == 9:
+			u = append(u, '\t', 0)
+		case c == 10:
+			u = append(u, '\n', 0)
+		case c == 24:
+			u = append(u, '-', 0)
+		case c == 30 || c == 31:
+			u = append(u, ' ', 0)
+		}
+		// inline and extended controls take 8 characters
+		if c != 0 && c != 10 && c != 13 && c < 24 {
+			i += 14
+		}
+	}
+	return ole.UTF16(u)
+}
+
+// Record tags
+const (
+	TagBegin      uint16 = 0x10
+	TagParaHeader        = TagBegin + 50
+	TagParaText          = TagBegin + 51
+	TagCtrlHeader        = TagBegin + 55
+)
+
+// Record is a tagged record
+type Record struct {
+	Tag   uint16
+	Level uint16
+	Data  []byte
+}
+
+// Reader iterates the records of a section or DocInfo stream
+type Reader struct {
+	r io.Reader
+}
+
+// NewReader returns a Reader for a decompressed stream
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: r}
+}
+
+// Next returns the next record, or io.EOF at the end of the stream
+func (r *Reader) Next() (*Record, error) {
+	var hdr [4]byte
+	if _, err := io.ReadFull(r.r, hdr[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = ErrFormat
+		}
+		return nil, err
+	}
+	v := binary.LittleEndian.Uint32(hdr[:])
+	rec := &Record{Tag: uint16(v & 0x3FF), Level: uint16(v >> 10 & 0x3FF)}
+	size := int64(v >> 20)
+	if size == 0xFFF {
+		if _, err := io.ReadFull(r.r, hdr[:]); err != nil {
+			return nil, ErrFormat
+		}
+		size = int64(binary.LittleEndian.Uint32(hdr[:]))
+	}
+	buf := &bytes.Buffer{}
+	if n, err := io.CopyN(buf, r.r, size); n < size {
+		if err == nil || err == io.EOF {
+			err = ErrFormat
+		}
+		return nil, err
+	}
+	rec.Data = buf.Bytes()
+	return rec, nil
+}
@@ -0,0 +1,125 @@
+package hwp
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/richardlehane/mscfb"
+	"github.com/richardlehane/mscfb/mscfbtest"
+)
+
+func header(version, flags uint32) []byte {
+	b := make([]byte, 256)
+	copy(b, signature)
+	binary.LittleEndian.PutUint32(b[32:], version)
+	binary.LittleEndian.PutUint32(b[36:], flags)
+	return b
+}
+
+func record(tag, level uint16, data []byte) []byte {
+	size := uint32(len(data))
+	if size >= 0xFFF {
+		size = 0xFFF
+	}
+	b := make([]byte, 4, 8+len(data))
+	binary.LittleEndian.PutUint32(b, uint32(tag)|uint32(level)<<10|size<<20)
+	if size == 0xFFF {
+		b = append(b, byte(len(data)), byte(len(data)>>8), byte(len(data)>>16), byte(len(data)>>24))
+	}
+	return append(b, data...)
+}
+
+func chars(v ...interface{}) []byte {
+	var u []uint16
+	for _, x := range v {
+		switch x := x.(type) {
+		case string:
+			u = append(u, utf16.Encode([]rune(x))...)
+		case int:
+			// a control: extended and inline controls take 8 characters
+			u = append(u, uint16(x))
+			if x != 10 && x != 13 && x < 24 {
+				u = append(u, 0x6C74, 0x6274, 0, 0, 0, 0, uint16(x))
+			}
+		}
+	}
+	b := make([]byte, len(u)*2)
+	for i, c := range u {
+		binary.LittleEndian.PutUint16(b[i*2:], c)
+	}
+	return b
+}
+
+func deflate(b []byte) []byte {
+	var buf bytes.Buffer
+	w, _ := flate.NewWriter(&buf, flate.DefaultCompression)
+	w.Write(b)
+	w.Close()
+	return buf.Bytes()
+}
+
+func document(t *testing.T, flags uint32, sections ...[]byte) *mscfb.Reader {
+	b := mscfbtest.New()
+	b.Stream("FileHeader", header(0x05000300, flags))
+	body := b.Storage("BodyText")
+	// sections are added out of order to check sorting
+	for i := len(sections) - 1; i >= 0; i-- {
+		s := sections[i]
+		if flags&FlagCompressed != 0 {
+			s = deflate(s)
+		}
+		body.Stream("Section"+string(rune('0'+i)), s)
+	}
+	buf, err := b.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := mscfb.New(bytes.NewReader(buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestText(t *testing.T) {
+	long := strings.Repeat("가나다", 1000)
+	s0 := bytes.Join([][]byte{
+		record(TagParaHeader, 0, make([]byte, 22)),
+		record(TagParaText, 1, chars(2, "안녕하세요", 9, "world", 10, "next", 30, "line", 13)),
+		record(TagParaHeader, 0, make([]byte, 22)),
+		record(TagParaText, 1, chars(11, 13)),
+		record(TagCtrlHeader, 1, []byte("tbl ")),
+		record(TagParaHeader, 2, make([]byte, 22)),
+		record(TagParaText, 3, chars("cell", 13)),
+	}, nil)
+	s1 := record(TagParaText, 1, chars(long, 13))
+	expect := "안녕하세요\tworld\nnext line\n\ncell\n" + long + "\n"
+	for _, flags := range []uint32{0, FlagCompressed} {
+		d, err := New(document(t, flags, s0, s1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.Header.Version.String() != "5.0.3.0" || d.Header.Compressed() != (flags != 0) || d.Sections() != 2 {
+			t.Errorf("bad header %+v", d.Header)
+		}
+		text, err := d.Text()
+		if err != nil || text != expect {
+			t.Errorf("expecting %q, got %q %v", expect, text, err)
+		}
+		rr, _ := d.Records(0)
+		rec, err := rr.Next()
+		if err != nil || rec.Tag != TagParaHeader || rec.Level != 0 || len(rec.Data) != 22 {
+			t.Errorf("bad record %+v %v", rec, err)
+		}
+	}
+}
+
+func TestEncrypted(t *testing.T) {
+	if _, err := New(document(t, FlagCompressed|FlagDistribution)); err != ErrEncrypted {
+		t.Errorf("expecting ErrEncrypted, got %v", err)
+	}
+}
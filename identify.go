@@ -0,0 +1,177 @@
+// Copyright 2013 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mscfb
+
+import (
+	"strings"
+)
+
+// Identity describes the application behind a storage
+type Identity struct {
+	Product string   // e.g. "Microsoft Word 97-2003 Document". Empty if the product couldn't be identified.
+	Basis   string   // what identified the product: "CLSID", "ProgID" or "streams"
+	CLSID   string   // the storage's CLSID
+	CompObj *CompObj // the storage's \x01CompObj stream, if any
+}
+
+const nullCLSID = "{00000000-0000-0000-0000-000000000000}"
+
+// CLSIDs maps well-known storage CLSIDs to products
+var CLSIDs = map[string]string{
+	"{00020900-0000-0000-C000-000000000046}": "Microsoft Word 6.0-95 Document",
+	"{00020906-0000-0000-C000-000000000046}": "Microsoft Word 97-2003 Document",
+	"{F4754C9B-64F5-4B40-8AF4-679732AC0607}": "Microsoft Word Document",
+	"{00020810-0000-0000-C000-000000000046}": "Microsoft Excel Workbook",
+	"{00020811-0000-0000-C000-000000000046}": "Microsoft Excel Chart",
+	"{00020820-0000-0000-C000-000000000046}": "Microsoft Excel 97-2003 Workbook",
+	"{00020821-0000-0000-C000-000000000046}": "Microsoft Excel 97-2003 Chart",
+	"{00020830-0000-0000-C000-000000000046}": "Microsoft Excel Workbook",
+	"{EA7BAE70-FB3B-11CD-A903-00AA00510EA3}": "Microsoft PowerPoint 95 Presentation",
+	"{64818D10-4F9B-11CF-86EA-00AA00B929E8}": "Microsoft PowerPoint 97-2003 Presentation",
+	"{64818D11-4F9B-11CF-86EA-00AA00B929E8}": "Microsoft PowerPoint 97-2003 Slide",
+	"{CF4F55F4-8F87-4D47-80BB-5808164BB3F8}": "Microsoft PowerPoint Presentation",
+	"{00020D0B-0000-0000-C000-000000000046}": "Microsoft Outlook Message",
+	"{000C1084-0000-0000-C000-000000000046}": "Windows Installer Package",
+	"{000C1082-0000-0000-C000-000000000046}": "Windows Installer Transform",
+	"{000C1086-0000-0000-C000-000000000046}": "Windows Installer Patch",
+	"{0002CE02-0000-0000-C000-000000000046}": "Microsoft Equation 3.0",
+	"{0003000C-0000-0000-C000-000000000046}": "OLE Package",
+	"{B801CA65-A1FC-11D0-85AD-444553540000}": "Adobe Acrobat Document",
+}
+
+// ProgIDs maps well-known ProgIDs to products. ProgIDs that aren't listed are also matched without their version suffix.
+var ProgIDs = map[string]string{
+	"Word.Document.6":    "Microsoft Word 6.0-95 Document",
+	"Word.Document.8":    "Microsoft Word 97-2003 Document",
+	"Word.Document":      "Microsoft Word Document",
+	"Word.Picture.8":     "Microsoft Word Picture",
+	"Excel.Sheet.5":      "Microsoft Excel Workbook",
+	"Excel.Sheet.8":      "Microsoft Excel 97-2003 Workbook",
+	"Excel.Sheet":        "Microsoft Excel Workbook",
+	"Excel.Chart.8":      "Microsoft Excel 97-2003 Chart",
+	"Excel.Chart":        "Microsoft Excel Chart",
+	"PowerPoint.Show.7":  "Microsoft PowerPoint 95 Presentation",
+	"PowerPoint.Show.8":  "Microsoft PowerPoint 97-2003 Presentation",
+	"PowerPoint.Show":    "Microsoft PowerPoint Presentation",
+	"PowerPoint.Slide.8": "Microsoft PowerPoint 97-2003 Slide",
+	"PowerPoint.Slide":   "Microsoft PowerPoint Slide",
+	"Visio.Drawing":      "Microsoft Visio Drawing",
+	"Publisher.Document": "Microsoft Publisher Document",
+	"MSProject.Project":  "Microsoft Project",
+	"MSGraph.Chart":      "Microsoft Graph Chart",
+	"Equation.3":         "Microsoft Equation 3.0",
+	"Equation.DSMT4":     "MathType Equation",
+	"Package":            "OLE Package",
+	"Paint.Picture":      "Paintbrush Picture",
+	"PBrush":             "Paintbrush Picture",
+	"AcroExch.Document":  "Adobe Acrobat Document",
+	"StaticMetafile":     "Picture (Metafile)",
+	"StaticDib":          "Picture (Device Independent Bitmap)",
+}
+
+// streams identifies products by the names of characteristic streams and storages. All names in a rule must be present.
+var streams = []struct {
+	names   []string
+	product string
+}{
+	{[]string{"WordDocument"}, "Microsoft Word 97-2003 Document"},
+	{[]string{"Workbook"}, "Microsoft Excel 97-2003 Workbook"},
+	{[]string{"Book"}, "Microsoft Excel 5.0-95 Workbook"},
+	{[]string{"PowerPoint Document"}, "Microsoft PowerPoint 97-2003 Presentation"},
+	{[]string{"__properties_version1.0", "__nameid_version1.0"}, "Microsoft Outlook Message"},
+	{[]string{"VisioDocument"}, "Microsoft Visio Drawing"},
+	{[]string{"Quill"}, "Microsoft Publisher Document"},
+	{[]string{"Equation Native"}, "Microsoft Equation 3.0"},
+	{[]string{"EncryptionInfo", "EncryptedPackage"}, "Encrypted Office Open XML Document"},
+	{[]string{"FileHeader", "BodyText"}, "Hangul Word Processor Document"},
+	{[]string{"Ole10Native"}, "OLE Package"},
+	{[]string{"DestList"}, "Windows Jump List"},
+	{[]string{"Catalog"}, "Windows Thumbnail Cache (Thumbs.db)"},
+}
+
+// Identify identifies the application behind the root storage or, if a path is given, the storage at that path.
+// The storage's CLSID is consulted first, then the ProgID in its \x01CompObj stream, then the names of the streams and
+// storages it contains.
+func (r *Reader) Identify(path ...string) Identity {
+	var id Identity
+	names := make(map[string]bool)
+	for i, f := range r.File {
+		switch {
+		case len(path) == 0 && i == 0:
+			id.CLSID = f.ID()
+		case len(f.Path) == len(path)-1 && f.Name == path[len(path)-1] && equalPath(f.Path, path[:len(path)-1]):
+			id.CLSID = f.ID()
+		case i > 0 && equalPath(f.Path, path):
+			names[f.Name] = true
+			if f.Initial == 1 && f.Name == "CompObj" {
+				id.CompObj, _ = f.CompObj()
+			}
+		}
+	}
+	if p, ok := CLSIDs[id.CLSID]; ok {
+		id.Product, id.Basis = p, "CLSID"
+		return id
+	}
+	if id.CompObj != nil && id.CompObj.ProgID != "" {
+		if p, ok := progID(id.CompObj.ProgID); ok {
+			id.Product, id.Basis = p, "ProgID"
+			return id
+		}
+	}
+	if id.CompObj != nil && id.CompObj.CLSID != nullCLSID {
+		if p, ok := CLSIDs[id.CompObj.CLSID]; ok {
+			id.Product, id.Basis = p, "CLSID"
+			return id
+		}
+	}
+	for _, rule := range streams {
+		match := true
+		for _, n := range rule.names {
+			if !names[n] {
+				match = false
+				break
+			}
+		}
+		if match {
+			id.Product, id.Basis = rule.product, "streams"
+			return id
+		}
+	}
+	return id
+}
+
+// progID looks up a ProgID, falling back to the ProgID without its version suffix e.g. Visio.Drawing.11 to Visio.Drawing
+func progID(s string) (string, bool) {
+	if p, ok := ProgIDs[s]; ok {
+		return p, true
+	}
+	if i := strings.LastIndexByte(s, '.'); i > 0 && strings.Trim(s[i+1:], "0123456789") == "" {
+		p, ok := ProgIDs[s[:i]]
+		return p, ok
+	}
+	return "", false
+}
+
+func equalPath(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,101 @@
+package mscfb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"testing"
+
+	"github.com/richardlehane/mscfb/mscfbtest"
+)
+
+func TestIdentify(t *testing.T) {
+	for path, expect := range map[string]string{
+		testDoc: "Microsoft Word 97-2003 Document",
+		testXls: "Microsoft Excel Workbook",
+		testPpt: "Microsoft PowerPoint 97-2003 Presentation",
+		testMsg: "Microsoft Outlook Message",
+	} {
+		file, _ := os.Open(path)
+		doc, err := New(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id := doc.Identify(); id.Product != expect || id.Basis != "CLSID" {
+			t.Errorf("%s: expecting %q, got %q (%s)", path, expect, id.Product, id.Basis)
+		}
+		file.Close()
+	}
+}
+
+func TestCompObj(t *testing.T) {
+	file, _ := os.Open(testDoc)
+	defer file.Close()
+	doc, err := New(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := doc.Identify()
+	if c := id.CompObj; c == nil || c.UserType != "Microsoft Office Word Document" || c.ClipboardFormat != "MSWordDoc" ||
+		c.ProgID != "Word.Document.8" || c.CLSID != "{00020906-0000-0000-C000-000000000046}" {
+		t.Errorf("bad CompObj %+v", c)
+	}
+}
+
+func lpstr(s string) []byte {
+	b := make([]byte, 4, 5+len(s))
+	binary.LittleEndian.PutUint32(b, uint32(len(s)+1))
+	return append(append(b, s...), 0)
+}
+
+func TestIdentifyStorage(t *testing.T) {
+	// CompObj with a standard clipboard format and the ProgID of Equation Editor, then the optional Unicode part
+	compobj := append(make([]byte, 28), lpstr("Microsoft Equation 3.0")...)
+	compobj = append(compobj, 0xFF, 0xFF, 0xFF, 0xFF, 3, 0, 0, 0)
+	compobj = append(compobj, lpstr("Equation.3")...)
+	compobj = append(compobj, 0xF4, 0x39, 0xB2, 0x71, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0)
+	b := mscfbtest.New()
+	b.Stream("VisioDocument", make([]byte, 10))
+	b.Storage("ObjectPool").Storage("_1").
+		Stream("\x01CompObj", compobj).
+		Stream("Equation Native", make([]byte, 10))
+	b.Storage("ObjectPool").Storage("_2").Stream("\x01CompObj", append(make([]byte, 28), append(lpstr("Drawing"), append(make([]byte, 4), lpstr("Visio.Drawing.11")...)...)...))
+	buf, err := b.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := New(bytes.NewReader(buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id := doc.Identify(); id.Product != "Microsoft Visio Drawing" || id.Basis != "streams" {
+		t.Errorf("expecting Visio from stream names, got %q (%s)", id.Product, id.Basis)
+	}
+	id := doc.Identify("ObjectPool", "_1")
+	if id.Product != "Microsoft Equation 3.0" || id.Basis != "ProgID" || id.CompObj.ClipboardFormat != "CF_METAFILEPICT" {
+		t.Errorf("expecting Equation Editor from ProgID, got %q (%s) %+v", id.Product, id.Basis, id.CompObj)
+	}
+	if id := doc.Identify("ObjectPool", "_2"); id.Product != "Microsoft Visio Drawing" {
+		t.Errorf("expecting Visio from versionless ProgID, got %q", id.Product)
+	}
+	if id := doc.Identify("ObjectPool"); id.Product != "" {
+		t.Errorf("expecting no product, got %q", id.Product)
+	}
+}
+
+func TestCompObjSize(t *testing.T) {
+	compobj := append(make([]byte, 28), lpstr("Drawing")...)
+	b := mscfbtest.New()
+	b.Stream("\x01CompObj", append(compobj, make([]byte, 4096-len(compobj))...))
+	buf, err := b.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := New(bytes.NewReader(buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := doc.File[1].CompObj(); err == nil {
+		t.Error("expecting an error for a CompObj stream outside the mini stream")
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright 2013 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ole holds the helpers shared by the format packages: string decoding, FILETIMEs and whole stream reads.
+package ole
+
+import (
+	"encoding/binary"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/richardlehane/mscfb"
+)
+
+// ReadAll reads the whole of a stream. Sizes that are negative or larger than the compound file are an error.
+func ReadAll(f *mscfb.File) ([]byte, error) {
+	if _, err := f.Extents(); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, f.Size)
+	if f.Size == 0 {
+		return buf, nil
+	}
+	n, err := f.ReadAt(buf, 0)
+	if n == len(buf) {
+		return buf, nil
+	}
+	if err == nil {
+		err = io.ErrUnexpectedEOF
+	}
+	return nil, err
+}
+
+// UTF16 decodes UTF-16LE text
+func UTF16(b []byte) string {
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = binary.LittleEndian.Uint16(b[i*2:])
+	}
+	return string(utf16.Decode(u))
+}
+
+// EncodeUTF16 encodes text as UTF-16LE
+func EncodeUTF16(s string) []byte {
+	u := utf16.Encode([]rune(s))
+	b := make([]byte, len(u)*2)
+	for i, v := range u {
+		binary.LittleEndian.PutUint16(b[i*2:], v)
+	}
+	return b
+}
+
+// UTF16Z decodes UTF-16LE text up to the first null character
+func UTF16Z(b []byte) string {
+	for i := 0; i+1 < len(b); i += 2 {
+		if b[i] == 0 && b[i+1] == 0 {
+			return UTF16(b[:i])
+		}
+	}
+	return UTF16(b)
+}
+
+// Latin1 decodes ISO-8859-1 text, such as UTF-16 stored as the low bytes of its code units
+func Latin1(b []byte) string {
+	r := make([]rune, len(b))
+	for i, c := range b {
+		r[i] = rune(c)
+	}
+	return string(r)
+}
+
+// cp1252 maps the bytes 0x80-0x9F of Windows-1252 that differ from Latin-1
+var cp1252 = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8D, 'Ž', 0x8F,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9D, 'ž', 'Ÿ',
+}
+
+// Windows1252 decodes Windows-1252 text
+func Windows1252(b []byte) string {
+	var sb strings.Builder
+	for _, c := range b {
+		switch {
+		case c < 0x80:
+			sb.WriteByte(c)
+		case c < 0xA0:
+			sb.WriteRune(cp1252[c-0x80])
+		default:
+			sb.WriteRune(rune(c))
+		}
+	}
+	return sb.String()
+}
+
+// Decode decodes MBCS text. Code pages other than UTF-8 are treated as Windows-1252.
+func Decode(b []byte, codePage int) string {
+	if codePage == 65001 {
+		return string(b)
+	}
+	return Windows1252(b)
+}
+
+// Filetime converts a Windows FILETIME (100 nanosecond intervals since 1601) to a time.Time
+func Filetime(ft uint64) time.Time {
+	if ft == 0 {
+		return time.Time{}
+	}
+	const epochDiff = 116444736000000000 // 1601 to 1970 in 100ns intervals
+	return time.Unix(0, (int64(ft)-epochDiff)*100).UTC()
+}
@@ -0,0 +1,65 @@
+package ole
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/richardlehane/mscfb"
+	"github.com/richardlehane/mscfb/mscfbtest"
+)
+
+func TestText(t *testing.T) {
+	u := []byte{'h', 0, 'i', 0, 0, 0, 'x', 0}
+	if s := UTF16(u); s != "hi\x00x" {
+		t.Errorf("expecting hi\\x00x, got %q", s)
+	}
+	if s := UTF16Z(u); s != "hi" {
+		t.Errorf("expecting hi, got %q", s)
+	}
+	if s := Decode([]byte{'a', 0x80, 0x93, 0xE9}, 1252); s != "a€“é" {
+		t.Errorf("expecting a€“é, got %q", s)
+	}
+	if s := Latin1([]byte{'a', 0x93, 0xE9}); s != "a\u0093é" {
+		t.Errorf("expecting a\\u0093é, got %q", s)
+	}
+	if s := Decode([]byte("é"), 65001); s != "é" {
+		t.Errorf("expecting é, got %q", s)
+	}
+}
+
+func TestFiletime(t *testing.T) {
+	if ft := Filetime(116444736000000000); !ft.Equal(time.Unix(0, 0)) {
+		t.Errorf("expecting the unix epoch, got %v", ft)
+	}
+	if !Filetime(0).IsZero() {
+		t.Error("expecting a zero time")
+	}
+}
+
+func TestReadAll(t *testing.T) {
+	for _, sz := range []uint64{5000, 1 << 40, 1 << 63} {
+		b := mscfbtest.New().Version(4)
+		b.Stream("Stream", bytes.Repeat([]byte("x"), 5000))
+		doc, err := b.Corrupt(mscfbtest.Size("Stream", sz)).Bytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := mscfb.New(bytes.NewReader(doc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, err := r.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf, err := ReadAll(f)
+		if sz == 5000 {
+			if err != nil || len(buf) != 5000 {
+				t.Errorf("bad read: %d bytes, %v", len(buf), err)
+			}
+		} else if err == nil {
+			t.Errorf("size %d: expecting an error", sz)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2013 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package oletest builds the VBA and embedded object streams shared by the format packages' tests.
+package oletest
+
+// U16 and U32 encode little endian integers
+func U16(v uint16) []byte { return []byte{byte(v), byte(v >> 8)} }
+func U32(v uint32) []byte { return []byte{byte(v), byte(v >> 8), byte(v >> 16), byte(v >> 24)} }
+
+// Compress builds a CompressedContainer (MS-OVBA 2.4.1) of literal tokens only
+func Compress(b []byte) []byte {
+	out := []byte{0x01}
+	for len(b) > 0 {
+		n := len(b)
+		if n > 3500 {
+			n = 3500
+		}
+		var chunk []byte
+		for i := 0; i < n; i += 8 {
+			e := i + 8
+			if e > n {
+				e = n
+			}
+			chunk = append(append(chunk, 0), b[i:e]...)
+		}
+		out = append(append(out, U16(uint16(len(chunk)-1)|0xB000)...), chunk...)
+		b = b[n:]
+	}
+	return out
+}
+
+// Record builds a record of a VBA dir stream (MS-OVBA 2.3.4.2)
+func Record(id uint16, data []byte) []byte {
+	return append(append(U16(id), U32(uint32(len(data)))...), data...)
+}
+
+// Native builds a \x01Ole10Native stream for a file embedded with the Packager.
+// A non-empty unicodeLabel adds the Unicode label and paths written by later versions.
+func Native(label, src string, payload []byte, unicodeLabel string) []byte {
+	tmp := "C:\\Temp\\" + label + "\x00"
+	b := U16(2)
+	b = append(b, label+"\x00"+src+"\x00"...)
+	b = append(append(b, 0, 0), U16(3)...)
+	b = append(append(b, U32(uint32(len(tmp)))...), tmp...)
+	b = append(append(b, U32(uint32(len(payload)))...), payload...)
+	if unicodeLabel != "" {
+		for _, s := range []string{"C:\\Temp\\x", unicodeLabel, "C:\\src\\x"} {
+			r := []rune(s)
+			b = append(b, U32(uint32(len(r)))...)
+			for _, c := range r {
+				b = append(b, U16(uint16(c))...)
+			}
+		}
+	}
+	return append(U32(uint32(len(b))), b...)
+}
@@ -0,0 +1,155 @@
+// Copyright 2013 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package jumplist reads Windows Jump Lists (.automaticDestinations-ms files).
+//
+// A Jump List has a DestList stream of most recently used entries. Each entry has a stream, named with the entry number
+// in hexadecimal, holding a Shell Link to the target.
+//
+// Example:
+//
+//	doc, _ := mscfb.New(file)
+//	jl, err := jumplist.New(doc)
+//	if err != nil {
+//	  log.Fatal(err)
+//	}
+//	for _, e := range jl.Entries {
+//	  fmt.Println(e.Modified, e.AccessCount, e.Hostname, e.Target())
+//	}
+package jumplist
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/richardlehane/mscfb"
+	"github.com/richardlehane/mscfb/internal/ole"
+)
+
+// ErrFormat is returned for compound files without a DestList stream, and for malformed DestList streams
+var ErrFormat = errors.New("jumplist: bad jump list")
+
+// Entry is a DestList entry
+type Entry struct {
+	Number        uint32
+	Hostname      string // NetBIOS name of the computer the target was on
+	Path          string // path of the target, as recorded in the DestList
+	Modified      time.Time
+	AccessCount   int
+	Pinned        bool
+	PinPosition   int    // position in the pinned list, if pinned
+	VolumeID      string // distributed link tracking volume and file object identifiers
+	FileID        string
+	BirthVolumeID string
+	BirthFileID   string
+	Link          *Link // shell link from the entry's stream, or nil if the stream is missing or malformed
+}
+
+// Target returns the target path from the shell link, or the DestList path if that isn't available
+func (e Entry) Target() string {
+	if e.Link != nil && e.Link.Target != "" {
+		return e.Link.Target
+	}
+	return e.Path
+}
+
+// JumpList is an automatic destinations Jump List
+type JumpList struct {
+	Version uint32 // 1 for Windows 7 and 8; 3 or 4 for Windows 10 and later
+	Entries []Entry
+}
+
+// New reads a Jump List, joining DestList entries to their shell links
+func New(r *mscfb.Reader) (*JumpList, error) {
+	files := make(map[string]*mscfb.File)
+	for _, f := range r.File {
+		if !f.FileInfo().IsDir() && len(f.Path) == 0 {
+			files[strings.ToLower(f.Name)] = f
+		}
+	}
+	dl, ok := files["destlist"]
+	if !ok {
+		return nil, ErrFormat
+	}
+	buf, err := ole.ReadAll(dl)
+	if err != nil {
+		return nil, err
+	}
+	if len(buf) < 32 {
+		return nil, ErrFormat
+	}
+	jl := &JumpList{Version: binary.LittleEndian.Uint32(buf)}
+	count := binary.LittleEndian.Uint32(buf[4:])
+	for b := buf[32:]; count > 0 && len(b) > 0; count-- {
+		e, n, err := entry(b, jl.Version)
+		if err != nil {
+			return nil, err
+		}
+		b = b[n:]
+		if f, ok := files[strconv.FormatUint(uint64(e.Number), 16)]; ok {
+			if lb, err := ole.ReadAll(f); err == nil {
+				e.Link, _ = ParseLink(lb)
+			}
+		}
+		jl.Entries = append(jl.Entries, e)
+	}
+	return jl, nil
+}
+
+// entry parses a DestList entry, returning the number of bytes consumed
+func entry(b []byte, version uint32) (Entry, int, error) {
+	pathOff, tail := 112, 0
+	if version > 1 {
+		pathOff, tail = 128, 4
+	}
+	if len(b) < pathOff+2 {
+		return Entry{}, 0, ErrFormat
+	}
+	n := pathOff + 2 + int(binary.LittleEndian.Uint16(b[pathOff:]))*2 + tail
+	if n > len(b) {
+		return Entry{}, 0, ErrFormat
+	}
+	e := Entry{
+		VolumeID:      guid(b[8:]),
+		FileID:        guid(b[24:]),
+		BirthVolumeID: guid(b[40:]),
+		BirthFileID:   guid(b[56:]),
+		Hostname:      string(b[72:88]),
+		Number:        binary.LittleEndian.Uint32(b[88:]),
+		Modified:      ole.Filetime(binary.LittleEndian.Uint64(b[100:])),
+		Path:          ole.UTF16Z(b[pathOff+2 : n-tail]),
+	}
+	if pin := int32(binary.LittleEndian.Uint32(b[108:])); pin >= 0 {
+		e.Pinned, e.PinPosition = true, int(pin)
+	}
+	if version > 1 {
+		e.AccessCount = int(binary.LittleEndian.Uint32(b[116:]))
+	} else {
+		e.AccessCount = int(math.Float32frombits(binary.LittleEndian.Uint32(b[96:])))
+	}
+	if i := strings.IndexByte(e.Hostname, 0); i >= 0 {
+		e.Hostname = e.Hostname[:i]
+	}
+	return e, n, nil
+}
+
+func guid(b []byte) string {
+	return fmt.Sprintf("{%08X-%04X-%04X-%X-%X}", binary.LittleEndian.Uint32(b), binary.LittleEndian.Uint16(b[4:]),
+		binary.LittleEndian.Uint16(b[6:]), b[8:10], b[10:16])
+}
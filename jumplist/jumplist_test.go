@@ -0,0 +1,138 @@
+package jumplist
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	"github.com/richardlehane/mscfb"
+	"github.com/richardlehane/mscfb/mscfbtest"
+)
+
+func ft(t time.Time) uint64 { return uint64(t.UnixNano()/100 + 116444736000000000) }
+
+func u16s(s string) []byte {
+	var b []byte
+	for _, c := range utf16.Encode([]rune(s)) {
+		b = append(b, byte(c), byte(c>>8))
+	}
+	return b
+}
+
+// lnk builds a unicode shell link with an item ID list, a LinkInfo with a local base path and Unicode strings,
+// and arguments
+func lnk(mod time.Time, base, suffix, args string) []byte {
+	b := make([]byte, linkHeaderSize)
+	binary.LittleEndian.PutUint32(b, linkHeaderSize)
+	binary.LittleEndian.PutUint32(b[20:], hasLinkTargetIDList|hasLinkInfo|hasArguments|isUnicode)
+	binary.LittleEndian.PutUint64(b[44:], ft(mod))
+	binary.LittleEndian.PutUint32(b[52:], 1234)
+	b = append(b, 4, 0, 0xAA, 0xBB, 0, 0)
+	info := make([]byte, 0x24)
+	binary.LittleEndian.PutUint32(info[4:], 0x24)
+	binary.LittleEndian.PutUint32(info[8:], volumeIDAndLocalBasePath)
+	binary.LittleEndian.PutUint32(info[16:], uint32(len(info)))
+	info = append(info, "C:\\WRONG\x00"...)
+	binary.LittleEndian.PutUint32(info[24:], uint32(len(info)))
+	info = append(info, 0)
+	binary.LittleEndian.PutUint32(info[28:], uint32(len(info)))
+	info = append(info, u16s(base+"\x00")...)
+	binary.LittleEndian.PutUint32(info[32:], uint32(len(info)))
+	info = append(info, u16s(suffix+"\x00")...)
+	binary.LittleEndian.PutUint32(info, uint32(len(info)))
+	b = append(b, info...)
+	b = append(b, byte(len(args)), 0)
+	return append(b, u16s(args)...)
+}
+
+func destEntry(version uint32, num uint32, host, path string, mod time.Time, count int, pin int32) []byte {
+	pathOff := 112
+	if version > 1 {
+		pathOff = 128
+	}
+	b := make([]byte, pathOff+2)
+	b[8] = 0x11
+	copy(b[72:], host)
+	binary.LittleEndian.PutUint32(b[88:], num)
+	binary.LittleEndian.PutUint64(b[100:], ft(mod))
+	binary.LittleEndian.PutUint32(b[108:], uint32(pin))
+	if version > 1 {
+		binary.LittleEndian.PutUint32(b[116:], uint32(count))
+	} else {
+		binary.LittleEndian.PutUint32(b[96:], math.Float32bits(float32(count)))
+	}
+	binary.LittleEndian.PutUint16(b[pathOff:], uint16(len(path)))
+	b = append(b, u16s(path)...)
+	if version > 1 {
+		b = append(b, 0, 0, 0, 0)
+	}
+	return b
+}
+
+func TestJumpList(t *testing.T) {
+	mod := time.Date(2021, 6, 1, 9, 0, 0, 0, time.UTC)
+	for _, version := range []uint32{1, 4} {
+		dl := make([]byte, 32)
+		binary.LittleEndian.PutUint32(dl, version)
+		binary.LittleEndian.PutUint32(dl[4:], 2)
+		dl = append(dl, destEntry(version, 10, "WORKSTATION", "C:\\Users\\bob\\report.docx", mod, 3, -1)...)
+		dl = append(dl, destEntry(version, 11, "FILESRV", "\\\\FILESRV\\share\\plan.xlsx", mod.Add(time.Hour), 7, 0)...)
+		b := mscfbtest.New()
+		b.Stream("DestList", dl)
+		b.Stream("a", lnk(mod, "C:\\Users\\bob\\", "report.docx", "/safe"))
+		buf, err := b.Bytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := mscfb.New(bytes.NewReader(buf))
+		if err != nil {
+			t.Fatal(err)
+		}
+		jl, err := New(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if jl.Version != version || len(jl.Entries) != 2 {
+			t.Fatalf("v%d: bad jump list %+v", version, jl)
+		}
+		e := jl.Entries[0]
+		if e.Number != 10 || e.Hostname != "WORKSTATION" || e.AccessCount != 3 || e.Pinned || !e.Modified.Equal(mod) ||
+			e.VolumeID != "{00000011-0000-0000-0000-000000000000}" {
+			t.Errorf("v%d: bad entry %+v", version, e)
+		}
+		if e.Link == nil || e.Target() != "C:\\Users\\bob\\report.docx" || e.Link.Arguments != "/safe" || e.Link.FileSize != 1234 ||
+			!e.Link.Modified.Equal(mod) {
+			t.Errorf("v%d: bad link %+v", version, e.Link)
+		}
+		e = jl.Entries[1]
+		if e.Link != nil || e.Target() != "\\\\FILESRV\\share\\plan.xlsx" || !e.Pinned || e.PinPosition != 0 || e.AccessCount != 7 {
+			t.Errorf("v%d: bad entry %+v", version, e)
+		}
+	}
+}
+
+func TestNetworkLink(t *testing.T) {
+	b := make([]byte, linkHeaderSize)
+	binary.LittleEndian.PutUint32(b, linkHeaderSize)
+	binary.LittleEndian.PutUint32(b[20:], hasLinkInfo)
+	info := make([]byte, 0x1C)
+	binary.LittleEndian.PutUint32(info[4:], 0x1C)
+	binary.LittleEndian.PutUint32(info[8:], commonNetworkRelativeLinkAndPathSuffix)
+	binary.LittleEndian.PutUint32(info[20:], 0x1C)
+	cnrl := make([]byte, 0x14)
+	binary.LittleEndian.PutUint32(cnrl[8:], 0x14)
+	info = append(append(info, cnrl...), "\\\\SERVER\\SHARE\x00"...)
+	binary.LittleEndian.PutUint32(info[24:], uint32(len(info)))
+	info = append(info, "docs\\a.txt\x00"...)
+	binary.LittleEndian.PutUint32(info, uint32(len(info)))
+	l, err := ParseLink(append(b, info...))
+	if err != nil || l.Target != "\\\\SERVER\\SHARE\\docs\\a.txt" {
+		t.Errorf("bad network link %+v %v", l, err)
+	}
+	if _, err := ParseLink(b[:20]); err != ErrLink {
+		t.Errorf("expecting ErrLink, got %v", err)
+	}
+}
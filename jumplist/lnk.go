@@ -0,0 +1,194 @@
+// Copyright 2013 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jumplist
+
+import (
+	"encoding/binary"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/richardlehane/mscfb/internal/ole"
+)
+
+// ErrLink is returned by ParseLink for malformed Shell Link structures
+var ErrLink = errors.New("jumplist: bad shell link")
+
+// Shell link flags
+const (
+	hasLinkTargetIDList = 1 << iota
+	hasLinkInfo
+	hasName
+	hasRelativePath
+	hasWorkingDir
+	hasArguments
+	hasIconLocation
+	isUnicode
+)
+
+const linkHeaderSize = 0x4C
+
+// Link is a Shell Link (MS-SHLLINK)
+type Link struct {
+	Flags        uint32
+	Attributes   uint32 // file attributes of the target
+	Created      time.Time
+	Accessed     time.Time
+	Modified     time.Time
+	FileSize     uint32
+	Target       string // local or network path of the target, from the LinkInfo structure
+	Name         string // description
+	RelativePath string
+	WorkingDir   string
+	Arguments    string
+	IconLocation string
+}
+
+// ParseLink parses a Shell Link structure. Targets only identified by an item ID list aren't resolved.
+func ParseLink(b []byte) (*Link, error) {
+	if len(b) < linkHeaderSize || binary.LittleEndian.Uint32(b) != linkHeaderSize {
+		return nil, ErrLink
+	}
+	l := &Link{
+		Flags:      binary.LittleEndian.Uint32(b[20:]),
+		Attributes: binary.LittleEndian.Uint32(b[24:]),
+		Created:    ole.Filetime(binary.LittleEndian.Uint64(b[28:])),
+		Accessed:   ole.Filetime(binary.LittleEndian.Uint64(b[36:])),
+		Modified:   ole.Filetime(binary.LittleEndian.Uint64(b[44:])),
+		FileSize:   binary.LittleEndian.Uint32(b[52:]),
+	}
+	b = b[linkHeaderSize:]
+	if l.Flags&hasLinkTargetIDList != 0 {
+		if len(b) < 2 {
+			return nil, ErrLink
+		}
+		sz := int(binary.LittleEndian.Uint16(b)) + 2
+		if sz > len(b) {
+			return nil, ErrLink
+		}
+		b = b[sz:]
+	}
+	if l.Flags&hasLinkInfo != 0 {
+		if len(b) < 4 {
+			return nil, ErrLink
+		}
+		sz := int(binary.LittleEndian.Uint32(b))
+		if sz < 4 || sz > len(b) {
+			return nil, ErrLink
+		}
+		var err error
+		if l.Target, err = linkInfo(b[:sz]); err != nil {
+			return nil, err
+		}
+		b = b[sz:]
+	}
+	for _, f := range []struct {
+		flag uint32
+		s    *string
+	}{
+		{hasName, &l.Name},
+		{hasRelativePath, &l.RelativePath},
+		{hasWorkingDir, &l.WorkingDir},
+		{hasArguments, &l.Arguments},
+		{hasIconLocation, &l.IconLocation},
+	} {
+		if l.Flags&f.flag == 0 {
+			continue
+		}
+		if len(b) < 2 {
+			return nil, ErrLink
+		}
+		n := int(binary.LittleEndian.Uint16(b))
+		b = b[2:]
+		if l.Flags&isUnicode != 0 {
+			n *= 2
+		}
+		if n > len(b) {
+			return nil, ErrLink
+		}
+		if l.Flags&isUnicode != 0 {
+			*f.s = ole.UTF16Z(b[:n])
+		} else {
+			*f.s = string(b[:n])
+		}
+		b = b[n:]
+	}
+	return l, nil
+}
+
+// LinkInfo flags
+const (
+	volumeIDAndLocalBasePath               = 1
+	commonNetworkRelativeLinkAndPathSuffix = 2
+)
+
+// linkInfo returns the target path from a LinkInfo structure, preferring the Unicode strings if present
+func linkInfo(b []byte) (string, error) {
+	if len(b) < 0x1C {
+		return "", ErrLink
+	}
+	hdrSize := binary.LittleEndian.Uint32(b[4:])
+	flags := binary.LittleEndian.Uint32(b[8:])
+	ansi := func(off int) string {
+		s, _ := cstring(b, int(binary.LittleEndian.Uint32(b[off:])), false)
+		return s
+	}
+	var base, suffix string
+	suffix = ansi(24)
+	if hdrSize >= 0x24 && len(b) >= 0x24 {
+		if s, ok := cstring(b, int(binary.LittleEndian.Uint32(b[32:])), true); ok {
+			suffix = s
+		}
+	}
+	switch {
+	case flags&volumeIDAndLocalBasePath != 0:
+		base = ansi(16)
+		if hdrSize >= 0x24 && len(b) >= 0x24 {
+			if s, ok := cstring(b, int(binary.LittleEndian.Uint32(b[28:])), true); ok {
+				base = s
+			}
+		}
+	case flags&commonNetworkRelativeLinkAndPathSuffix != 0:
+		off := int(binary.LittleEndian.Uint32(b[20:]))
+		if off <= 0 || off+0x14 > len(b) {
+			return "", ErrLink
+		}
+		base, _ = cstring(b, off+int(binary.LittleEndian.Uint32(b[off+8:])), false)
+		if binary.LittleEndian.Uint32(b[off+8:]) > 0x14 && off+0x18 <= len(b) {
+			if s, ok := cstring(b, off+int(binary.LittleEndian.Uint32(b[off+0x14:])), true); ok {
+				base = s
+			}
+		}
+		if suffix != "" && !strings.HasSuffix(base, "\\") {
+			base += "\\"
+		}
+	}
+	return base + suffix, nil
+}
+
+// cstring reads a null-terminated string at an offset in b. It reports false if the offset is out of range.
+func cstring(b []byte, off int, unicode bool) (string, bool) {
+	if off <= 0 || off >= len(b) {
+		return "", false
+	}
+	b = b[off:]
+	if unicode {
+		return ole.UTF16Z(b), true
+	}
+	if i := strings.IndexByte(string(b), 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b), true
+}
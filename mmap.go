@@ -0,0 +1,145 @@
+// Copyright 2013 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mscfb
+
+import (
+	"io"
+	"os"
+)
+
+// mapping is a compound file held in memory: either a file mapped into memory, or, if f is nil, a buffer. It
+// implements Slicer, so reads of headers, directory entries and streams return sub-slices rather than copies.
+type mapping struct {
+	b []byte
+	f *os.File
+}
+
+func (m *mapping) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 || off >= int64(len(m.b)) {
+		return 0, io.EOF
+	}
+	n := copy(p, m.b[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (m *mapping) Slice(offset int64, length int) ([]byte, error) {
+	if offset < 0 || length < 0 || offset+int64(length) > int64(len(m.b)) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return m.b[offset : offset+int64(length) : offset+int64(length)], nil
+}
+
+func (m *mapping) Size() int64 { return int64(len(m.b)) }
+
+func (m *mapping) Close() error {
+	if m.f == nil {
+		return nil
+	}
+	err := munmap(m.b)
+	if cerr := m.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// OpenFile opens a compound file by path. On Linux the file is memory mapped, so Bytes and Slices return
+// sub-slices of the mapping without copying. Elsewhere, or if the file can't be mapped, it is read through an *os.File.
+// The Reader must be closed with Close.
+func OpenFile(path string) (*Reader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	var ra io.ReaderAt = f
+	var closer io.Closer = f
+	if b, err := mmap(f, fi.Size()); err == nil {
+		m := &mapping{b: b, f: f}
+		ra, closer = m, m
+	}
+	r, err := New(ra)
+	if err != nil {
+		closer.Close()
+		return nil, err
+	}
+	r.closer = closer
+	return r, nil
+}
+
+// Close closes a Reader returned by OpenFile or NewFromReader, unmapping the file and removing any temporary file.
+// Slices returned by Bytes and Slices are invalid after Close. Close does nothing for Readers returned by New.
+func (r *Reader) Close() error {
+	if r.closer == nil {
+		return nil
+	}
+	err := r.closer.Close()
+	r.closer = nil
+	return err
+}
+
+// Bytes returns the contents of this stream. If the Reader was opened with OpenFile, or New was given a reader that
+// implements Slice, and the stream is held in contiguous sectors, the returned slice references the underlying data
+// directly and must not be modified. Otherwise the contents are copied.
+func (f *File) Bytes() ([]byte, error) {
+	slices, err := f.Slices()
+	if err != nil {
+		return nil, err
+	}
+	if len(slices) == 1 {
+		return slices[0], nil
+	}
+	b := make([]byte, 0, f.Size)
+	for _, s := range slices {
+		b = append(b, s...)
+	}
+	return b, nil
+}
+
+// Slices returns the contents of this stream as a scatter list: a slice for each run of contiguous sectors. If the
+// Reader doesn't have a reader that implements Slice, the contents are copied into a single slice.
+func (f *File) Slices() ([][]byte, error) {
+	ext, err := f.Extents()
+	if err != nil {
+		return nil, err
+	}
+	if len(ext) == 0 {
+		return [][]byte{{}}, nil
+	}
+	if !f.r.slicer {
+		b := make([]byte, f.Size)
+		var idx int64
+		for _, e := range ext {
+			if _, err := f.r.read(f.reason(), b[idx:idx+e.Length], e.Offset); err != nil {
+				return nil, Error{ErrRead, "underlying reader fail (" + err.Error() + ")", e.Offset}
+			}
+			idx += e.Length
+		}
+		return [][]byte{b}, nil
+	}
+	ret := make([][]byte, len(ext))
+	for i, e := range ext {
+		if ret[i], err = f.r.slice(f.reason(), e.Offset, int(e.Length)); err != nil {
+			return nil, Error{ErrRead, "slicer read error (" + err.Error() + ")", e.Offset}
+		}
+	}
+	return ret, nil
+}
@@ -0,0 +1,104 @@
+package mscfb
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/richardlehane/mscfb/mscfbtest"
+)
+
+func TestOpenFile(t *testing.T) {
+	for _, path := range []string{testDoc, testXls, testPpt, testMsg, novPapPlan} {
+		doc, err := OpenFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if doc.slicer != (runtime.GOOS == "linux") {
+			t.Errorf("%s: expecting the file to be mapped on linux", path)
+		}
+		file, _ := os.Open(path)
+		cmp, _ := New(file)
+		for i, f := range doc.File {
+			if f.FileInfo().IsDir() {
+				continue
+			}
+			expect, err := io.ReadAll(cmp.File[i])
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := f.Bytes()
+			if err != nil || !bytes.Equal(b, expect) {
+				t.Errorf("%s: bad Bytes for %s (%v)", path, f.Name, err)
+			}
+			slices, err := f.Slices()
+			if err != nil || !bytes.Equal(bytes.Join(slices, nil), expect) {
+				t.Errorf("%s: bad Slices for %s (%v)", path, f.Name, err)
+			}
+		}
+		file.Close()
+		if err := doc.Close(); err != nil {
+			t.Errorf("%s: close error %v", path, err)
+		}
+	}
+}
+
+func TestBytes(t *testing.T) {
+	file, _ := os.Open(testXls)
+	defer file.Close()
+	doc, err := New(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range doc.File {
+		if f.Name != "Workbook" {
+			continue
+		}
+		f.Seek(100, 0)
+		b, err := f.Bytes()
+		if err != nil || int64(len(b)) != f.Size {
+			t.Errorf("bad Bytes %d %v", len(b), err)
+		}
+		if pos, _ := f.Seek(0, 1); pos != 100 {
+			t.Errorf("Bytes moved the seek position to %d", pos)
+		}
+	}
+	if err := doc.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+// oversized builds a version 4 file whose "Big" stream claims n bytes, and opens it with readers that do and don't
+// report their size
+func oversized(t *testing.T, n uint64) []*File {
+	b := mscfbtest.New().Version(4)
+	b.Stream("Big", make([]byte, 5000))
+	doc, err := b.Corrupt(mscfbtest.Size("Big", n)).Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ret []*File
+	for _, ra := range []io.ReaderAt{bytes.NewReader(doc), sliceReader{bytes.NewReader(doc)}, struct{ io.ReaderAt }{bytes.NewReader(doc)}} {
+		r, err := New(ra)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ret = append(ret, r.File[1])
+	}
+	return ret
+}
+
+func TestBytesOversize(t *testing.T) {
+	for _, n := range []uint64{1 << 62, 1 << 63, 1 << 30} {
+		for _, f := range oversized(t, n) {
+			if _, err := f.Bytes(); err == nil {
+				t.Errorf("%d: expecting an error from Bytes", n)
+			}
+			if _, err := f.Slices(); err == nil {
+				t.Errorf("%d: expecting an error from Slices", n)
+			}
+		}
+	}
+}
@@ -38,6 +38,7 @@ package mscfb
 import (
 	"encoding/binary"
 	"io"
+	"os"
 	"strconv"
 	"time"
 )
@@ -117,7 +118,7 @@ type header struct {
 }
 
 func (r *Reader) setHeader() error {
-	buf, err := r.readAt(0, lenHeader)
+	buf, err := r.readAt(ReasonHeader, 0, lenHeader)
 	if err != nil {
 		return err
 	}
@@ -170,7 +171,7 @@ func (r *Reader) setDifats() error {
 	off := r.header.difatSectorLoc
 	cycles := make(map[uint32]bool)
 	for i := 0; i < int(r.header.numDifatSectors); i++ {
-		buf, err := r.readAt(fileOffset(r.sectorSize, off), int(r.sectorSize))
+		buf, err := r.readAt(ReasonDIFAT, fileOffset(r.sectorSize, off), int(r.sectorSize))
 		if err != nil {
 			return Error{ErrFormat, "error setting DIFAT(" + err.Error() + ")", int64(off)}
 		}
@@ -233,9 +234,9 @@ func (r *Reader) setMiniStream() error {
 	return nil
 }
 
-func (r *Reader) readAt(offset int64, length int) ([]byte, error) {
+func (r *Reader) readAt(reason Reason, offset int64, length int) ([]byte, error) {
 	if r.slicer {
-		b, err := r.ra.(slicer).Slice(offset, length)
+		b, err := r.slice(reason, offset, length)
 		if err != nil {
 			return nil, Error{ErrRead, "slicer read error (" + err.Error() + ")", offset}
 		}
@@ -244,7 +245,7 @@ func (r *Reader) readAt(offset int64, length int) ([]byte, error) {
 	if length > len(r.buf) {
 		return nil, Error{ErrRead, "read length greater than read buffer", int64(length)}
 	}
-	if _, err := r.ra.ReadAt(r.buf[:length], offset); err != nil {
+	if _, err := r.read(reason, r.buf[:length], offset); err != nil {
 		return nil, Error{ErrRead, err.Error(), offset}
 	}
 	return r.buf[:length], nil
@@ -281,8 +282,12 @@ func (r *Reader) findNext(sn uint32, mini bool) (uint32, error) {
 	}
 	fatIndex := sn % entries // find position within FAT or MiniFAT sector
 	offset := fileOffset(r.sectorSize, sect) + int64(fatIndex*4)
+	reason := ReasonFAT
+	if mini {
+		reason = ReasonMiniFAT
+	}
 	buf := make([]byte, 4)
-	_, err := r.ra.ReadAt(buf, offset)
+	_, err := r.read(reason, buf, offset)
 	if err != nil {
 		return 0, Error{ErrRead, "bad read finding next sector (" + err.Error() + ")", offset}
 	}
@@ -299,14 +304,29 @@ type Reader struct {
 	direntries []*File // unordered raw directory entries
 	entry      int
 
-	ra io.ReaderAt
-	wa io.WriterAt
+	ra     io.ReaderAt
+	wa     io.WriterAt
+	size   int64     // length of ra, or -1 if it doesn't report one
+	closer io.Closer // set by OpenFile
+	cache  *Cache    // set if New is given a Cache directly, rather than wrapped
+
+	observer Observer
+	counters counters
 }
 
 // New returns a MSCFB reader
 func New(ra io.ReaderAt) (*Reader, error) {
-	r := &Reader{ra: ra}
-	if _, ok := ra.(slicer); ok {
+	return NewWithOptions(ra, Options{})
+}
+
+// NewWithOptions returns a MSCFB reader that reports reads and writes of ra to the Observer in opts.
+// The memory limit and temporary directory in opts are only used by NewFromReader.
+func NewWithOptions(ra io.ReaderAt, opts Options) (*Reader, error) {
+	r := &Reader{ra: ra, size: readerSize(ra), observer: opts.Observer}
+	if c, ok := ra.(*Cache); ok {
+		r.cache = c
+	}
+	if _, ok := ra.(Slicer); ok {
 		r.slicer = true
 	} else {
 		r.buf = make([]byte, lenHeader)
@@ -333,6 +353,23 @@ func New(ra io.ReaderAt) (*Reader, error) {
 	return r, nil
 }
 
+// readerSize returns the length of ra if it is a File or has a Size or Stat method (like *bytes.Reader and *os.File), or -1
+func readerSize(ra io.ReaderAt) int64 {
+	switch v := ra.(type) {
+	case *Cache:
+		return readerSize(v.ra)
+	case *File: // a nested compound file
+		return v.Size
+	case interface{ Size() int64 }:
+		return v.Size()
+	case interface{ Stat() (os.FileInfo, error) }:
+		if fi, err := v.Stat(); err == nil {
+			return fi.Size()
+		}
+	}
+	return -1
+}
+
 // ID returns the CLSID (class ID) field from the root directory entry
 func (r *Reader) ID() string {
 	return r.File[0].ID()
@@ -410,7 +447,8 @@ func (e Error) Typ() int {
 	return e.typ
 }
 
-// Slicer interface avoids a copy by obtaining a byte slice directly from the underlying reader
-type slicer interface {
+// Slicer interface avoids a copy by obtaining a byte slice directly from the underlying reader.
+// New uses Slice in place of ReadAt for readers that implement it, as do File.Bytes and File.Slices.
+type Slicer interface {
 	Slice(offset int64, length int) ([]byte, error)
 }
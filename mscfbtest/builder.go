@@ -0,0 +1,383 @@
+// Copyright 2013 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package mscfbtest builds Compound File Binary (MSCFB) files in memory for use in tests.
+//
+// Storages and streams are declared with a small DSL and deliberate corruptions can be applied to the result.
+// Corrupt applies the same corruptions to existing compound files.
+//
+// Example:
+//
+//	b := mscfbtest.New()
+//	b.Stream("Alpha", []byte("hello"))
+//	b.Storage("Bravo").Stream("Echo", make([]byte, 5000))
+//	b.Corrupt(mscfbtest.DirCycle())
+//	doc, err := b.Bytes()
+//	if err != nil {
+//	  log.Fatal(err)
+//	}
+//	_, err = mscfb.New(bytes.NewReader(doc))
+package mscfbtest
+
+import (
+	"encoding/binary"
+	"errors"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+const (
+	signature            uint64 = 0xE11AB1A1E011CFD0
+	miniStreamSectorSize uint32 = 64
+	miniStreamCutoffSize int    = 4096
+	dirEntrySize         uint32 = 128
+	lenHeader            int    = 512
+	numInitialDifats     int    = 109
+)
+
+const (
+	difatSect  uint32 = 0xFFFFFFFC
+	fatSect    uint32 = 0xFFFFFFFD
+	endOfChain uint32 = 0xFFFFFFFE
+	freeSect   uint32 = 0xFFFFFFFF
+	noStream   uint32 = 0xFFFFFFFF
+)
+
+// objectType types
+const (
+	unknown     uint8 = 0x0
+	storage     uint8 = 0x1
+	stream      uint8 = 0x2
+	rootStorage uint8 = 0x5
+)
+
+// Node is a storage or stream declared in a Builder
+type Node struct {
+	name     string
+	clsid    [16]byte
+	storage  bool
+	data     []byte
+	children []*Node
+}
+
+// Storage adds a child storage to this node and returns it
+func (n *Node) Storage(name string) *Node {
+	c := &Node{name: name, storage: true}
+	n.children = append(n.children, c)
+	return c
+}
+
+// Stream adds a child stream to this node. It returns the parent node so that calls can be chained.
+// Streams smaller than 4096 bytes are stored in the mini stream.
+func (n *Node) Stream(name string, data []byte) *Node {
+	n.children = append(n.children, &Node{name: name, data: data})
+	return n
+}
+
+// CLSID sets the class ID of a storage node
+func (n *Node) CLSID(id [16]byte) *Node {
+	n.clsid = id
+	return n
+}
+
+// Builder declares the contents of a compound file
+type Builder struct {
+	*Node       // the root storage
+	version     uint16
+	corruptions []Corruption
+}
+
+// New returns a Builder for a version 3 (512 byte sector) compound file
+func New() *Builder {
+	return &Builder{
+		Node:    &Node{name: "Root Entry", storage: true},
+		version: 3,
+	}
+}
+
+// Version sets the major version of the compound file: 3 (512 byte sectors) or 4 (4096 byte sectors)
+func (b *Builder) Version(v uint16) *Builder {
+	b.version = v
+	return b
+}
+
+// Corrupt queues corruptions to apply, in order, when the compound file is built
+func (b *Builder) Corrupt(c ...Corruption) *Builder {
+	b.corruptions = append(b.corruptions, c...)
+	return b
+}
+
+// Bytes builds the compound file and applies any corruptions
+func (b *Builder) Bytes() ([]byte, error) {
+	var ss uint32
+	switch b.version {
+	case 3:
+		ss = 512
+	case 4:
+		ss = 4096
+	default:
+		return nil, errors.New("mscfbtest: version must be 3 or 4")
+	}
+	// assign directory IDs and build the red-black (all black) sibling trees
+	var entries []*dirent
+	var place func(n *Node, typ uint8) (*dirent, error)
+	place = func(n *Node, typ uint8) (*dirent, error) {
+		if len(utf16.Encode([]rune(n.name))) > 31 {
+			return nil, errors.New("mscfbtest: name too long " + n.name)
+		}
+		d := &dirent{node: n, id: uint32(len(entries)), typ: typ, left: noStream, right: noStream, child: noStream}
+		entries = append(entries, d)
+		if !n.storage {
+			return d, nil
+		}
+		kids := make([]*dirent, len(n.children))
+		sorted := append([]*Node(nil), n.children...)
+		sort.SliceStable(sorted, func(i, j int) bool { return less(sorted[i].name, sorted[j].name) })
+		for i, c := range sorted {
+			t := stream
+			if c.storage {
+				t = storage
+			}
+			k, err := place(c, t)
+			if err != nil {
+				return nil, err
+			}
+			kids[i] = k
+		}
+		d.child = balance(kids)
+		return d, nil
+	}
+	root, err := place(b.Node, rootStorage)
+	if err != nil {
+		return nil, err
+	}
+	// gather the mini stream and count sectors
+	var mini []byte
+	var streamSecs uint32
+	for _, d := range entries {
+		if d.typ != stream || len(d.node.data) == 0 {
+			continue
+		}
+		if len(d.node.data) < miniStreamCutoffSize {
+			d.mini = true
+			d.start = uint32(len(mini)) / miniStreamSectorSize
+			mini = append(mini, pad(d.node.data, miniStreamSectorSize)...)
+			continue
+		}
+		streamSecs += count(len(d.node.data), ss)
+	}
+	miniSecs := uint32(len(mini)) / miniStreamSectorSize
+	dirSecs := count(len(entries)*int(dirEntrySize), ss)
+	miniFatSecs := count(int(miniSecs)*4, ss)
+	miniStreamSecs := count(len(mini), ss)
+	data := dirSecs + miniFatSecs + miniStreamSecs + streamSecs
+	per := ss / 4
+	var fatSecs, difatSecs uint32
+	for {
+		nf := count(int(data+fatSecs+difatSecs)*4, ss)
+		var nd uint32
+		if int(nf) > numInitialDifats {
+			nd = count((int(nf)-numInitialDifats)*4, ss-4)
+		}
+		if nf == fatSecs && nd == difatSecs {
+			break
+		}
+		fatSecs, difatSecs = nf, nd
+	}
+	total := fatSecs + difatSecs + data
+	buf := make([]byte, int(ss)*int(total+1))
+	fat := make([]uint32, fatSecs*per)
+	for i := range fat {
+		fat[i] = freeSect
+	}
+	var next uint32
+	alloc := func(n uint32, mark uint32) uint32 {
+		if n == 0 {
+			return endOfChain
+		}
+		start := next
+		for i := start; i < start+n; i++ {
+			if mark != 0 {
+				fat[i] = mark
+			} else if i == start+n-1 {
+				fat[i] = endOfChain
+			} else {
+				fat[i] = i + 1
+			}
+		}
+		next += n
+		return start
+	}
+	fatStart := alloc(fatSecs, fatSect)
+	difatStart := alloc(difatSecs, difatSect)
+	dirStart := alloc(dirSecs, 0)
+	miniFatStart := alloc(miniFatSecs, 0)
+	miniStart := alloc(miniStreamSecs, 0)
+	for _, d := range entries {
+		if d.typ != stream {
+			continue
+		}
+		d.size = uint64(len(d.node.data))
+		switch {
+		case d.size == 0:
+			d.start = endOfChain
+		case !d.mini:
+			d.start = alloc(count(len(d.node.data), ss), 0)
+			copy(buf[offset(ss, d.start):], d.node.data)
+		}
+	}
+	root.start, root.size = miniStart, uint64(len(mini))
+	if miniStreamSecs > 0 {
+		copy(buf[offset(ss, miniStart):], mini)
+	}
+	// mini FAT
+	miniFat := make([]uint32, miniFatSecs*per)
+	for i := range miniFat {
+		miniFat[i] = freeSect
+	}
+	for _, d := range entries {
+		if !d.mini {
+			continue
+		}
+		n := count(len(d.node.data), miniStreamSectorSize)
+		for i := d.start; i < d.start+n; i++ {
+			miniFat[i] = i + 1
+		}
+		miniFat[d.start+n-1] = endOfChain
+	}
+	if miniFatSecs > 0 {
+		putUint32s(buf[offset(ss, miniFatStart):], miniFat)
+	}
+	// FAT and DIFAT
+	putUint32s(buf[offset(ss, fatStart):], fat)
+	difats := make([]uint32, numInitialDifats+int(difatSecs*(per-1)))
+	for i := range difats {
+		difats[i] = freeSect
+	}
+	for i := uint32(0); i < fatSecs; i++ {
+		difats[i] = fatStart + i
+	}
+	for i := uint32(0); i < difatSecs; i++ {
+		o := offset(ss, difatStart+i)
+		s := numInitialDifats + int(i*(per-1))
+		putUint32s(buf[o:], difats[s:s+int(per-1)])
+		nxt := endOfChain
+		if i+1 < difatSecs {
+			nxt = difatStart + i + 1
+		}
+		binary.LittleEndian.PutUint32(buf[o+int64(ss)-4:], nxt)
+	}
+	// directory
+	for _, d := range entries {
+		d.put(buf[offset(ss, dirStart)+int64(d.id*dirEntrySize):])
+	}
+	for i := uint32(len(entries)); i < dirSecs*(ss/dirEntrySize); i++ {
+		(&dirent{left: noStream, right: noStream, child: noStream}).put(buf[offset(ss, dirStart)+int64(i*dirEntrySize):])
+	}
+	// header
+	h := buf[:lenHeader]
+	binary.LittleEndian.PutUint64(h[0:], signature)
+	binary.LittleEndian.PutUint16(h[24:], 0x003E)
+	binary.LittleEndian.PutUint16(h[26:], b.version)
+	binary.LittleEndian.PutUint16(h[28:], 0xFFFE)
+	if b.version == 4 {
+		binary.LittleEndian.PutUint16(h[30:], 0x000C)
+		binary.LittleEndian.PutUint32(h[40:], dirSecs)
+	} else {
+		binary.LittleEndian.PutUint16(h[30:], 0x0009)
+	}
+	binary.LittleEndian.PutUint16(h[32:], 0x0006)
+	binary.LittleEndian.PutUint32(h[44:], fatSecs)
+	binary.LittleEndian.PutUint32(h[48:], dirStart)
+	binary.LittleEndian.PutUint32(h[56:], uint32(miniStreamCutoffSize))
+	binary.LittleEndian.PutUint32(h[60:], miniFatStart)
+	binary.LittleEndian.PutUint32(h[64:], miniFatSecs)
+	binary.LittleEndian.PutUint32(h[68:], difatStart)
+	binary.LittleEndian.PutUint32(h[72:], difatSecs)
+	putUint32s(h[76:], difats[:numInitialDifats])
+	if len(b.corruptions) == 0 {
+		return buf, nil
+	}
+	return Corrupt(buf, b.corruptions...)
+}
+
+type dirent struct {
+	node               *Node
+	id                 uint32
+	typ                uint8
+	left, right, child uint32
+	start              uint32
+	size               uint64
+	mini               bool
+}
+
+func (d *dirent) put(b []byte) {
+	if d.node != nil {
+		name := utf16.Encode([]rune(d.node.name))
+		for i, c := range name {
+			binary.LittleEndian.PutUint16(b[i*2:], c)
+		}
+		binary.LittleEndian.PutUint16(b[64:], uint16(len(name)+1)*2)
+		copy(b[80:96], d.node.clsid[:])
+		b[67] = 1 // black
+	}
+	b[66] = d.typ
+	binary.LittleEndian.PutUint32(b[68:], d.left)
+	binary.LittleEndian.PutUint32(b[72:], d.right)
+	binary.LittleEndian.PutUint32(b[76:], d.child)
+	binary.LittleEndian.PutUint32(b[116:], d.start)
+	binary.LittleEndian.PutUint64(b[120:], d.size)
+}
+
+// balance links sorted siblings into a balanced binary tree and returns the ID of its root
+func balance(kids []*dirent) uint32 {
+	if len(kids) == 0 {
+		return noStream
+	}
+	mid := len(kids) / 2
+	kids[mid].left = balance(kids[:mid])
+	kids[mid].right = balance(kids[mid+1:])
+	return kids[mid].id
+}
+
+// less compares directory entry names as the spec requires: shorter names first, then by upper case
+func less(a, b string) bool {
+	la, lb := len(utf16.Encode([]rune(a))), len(utf16.Encode([]rune(b)))
+	if la != lb {
+		return la < lb
+	}
+	return strings.ToUpper(a) < strings.ToUpper(b)
+}
+
+func offset(ss, sn uint32) int64 {
+	return int64(sn+1) * int64(ss)
+}
+
+// count returns the number of sz sized sectors needed to hold l bytes
+func count(l int, sz uint32) uint32 {
+	return uint32((l + int(sz) - 1) / int(sz))
+}
+
+func pad(b []byte, sz uint32) []byte {
+	p := make([]byte, int(count(len(b), sz)*sz))
+	copy(p, b)
+	return p
+}
+
+func putUint32s(b []byte, vals []uint32) {
+	for i, v := range vals {
+		binary.LittleEndian.PutUint32(b[i*4:], v)
+	}
+}
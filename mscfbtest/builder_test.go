@@ -0,0 +1,136 @@
+package mscfbtest_test
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/richardlehane/mscfb"
+	"github.com/richardlehane/mscfb/mscfbtest"
+)
+
+func fill(n int, seed byte) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = seed + byte(i%251)
+	}
+	return b
+}
+
+func sample() *mscfbtest.Builder {
+	b := mscfbtest.New()
+	b.Stream("Alpha", fill(100, 1))
+	b.Storage("Bravo").Stream("Echo", fill(5000, 2)).Stream("Delta", fill(10, 3)).Storage("Indigo").Stream("Kilo", nil)
+	b.Stream("Charlie", fill(9000, 4))
+	return b
+}
+
+func read(t *testing.T, doc []byte) map[string][]byte {
+	r, err := mscfb.New(bytes.NewReader(doc))
+	if err != nil {
+		t.Fatalf("Error opening built file: %v", err)
+	}
+	ret := make(map[string][]byte)
+	for f, err := r.Next(); err == nil; f, err = r.Next() {
+		buf := &bytes.Buffer{}
+		if _, err := io.Copy(buf, f); err != nil {
+			t.Fatalf("Error reading %s: %v", f.Name, err)
+		}
+		ret[strings.Join(append(f.Path, f.Name), "/")] = buf.Bytes()
+	}
+	return ret
+}
+
+func TestBuild(t *testing.T) {
+	for _, v := range []uint16{3, 4} {
+		doc, err := sample().Version(v).Bytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := read(t, doc)
+		expect := map[string][]byte{
+			"Alpha":             fill(100, 1),
+			"Bravo":             {},
+			"Bravo/Echo":        fill(5000, 2),
+			"Bravo/Delta":       fill(10, 3),
+			"Bravo/Indigo":      {},
+			"Bravo/Indigo/Kilo": {},
+			"Charlie":           fill(9000, 4),
+		}
+		if len(got) != len(expect) {
+			t.Fatalf("Version %d: expecting %d entries, got %d", v, len(expect), len(got))
+		}
+		for k, e := range expect {
+			if !bytes.Equal(got[k], e) {
+				t.Errorf("Version %d: bad contents for %s", v, k)
+			}
+		}
+	}
+}
+
+func TestBuildDIFAT(t *testing.T) {
+	// more than 109 FAT sectors forces DIFAT sectors
+	b := mscfbtest.New()
+	big := fill(8000000, 5)
+	b.Stream("Big", big)
+	doc, err := b.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(read(t, doc)["Big"], big) {
+		t.Error("bad contents for stream spanning DIFAT")
+	}
+}
+
+func TestCorrupt(t *testing.T) {
+	for _, c := range []mscfbtest.Corruption{
+		mscfbtest.DirCycle(),
+		mscfbtest.SiblingCycle("Bravo/Echo"),
+		mscfbtest.DIFATCount(5),
+	} {
+		doc, err := sample().Corrupt(c).Bytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := mscfb.New(bytes.NewReader(doc)); err == nil {
+			t.Errorf("%s: expecting an error from mscfb.New", c)
+		}
+	}
+	if _, err := sample().Corrupt(mscfbtest.SiblingCycle("Zulu")).Bytes(); err == nil {
+		t.Error("expecting an error for a missing entry")
+	}
+}
+
+func TestCrossLink(t *testing.T) {
+	doc, err := sample().Corrupt(mscfbtest.CrossLink("Charlie", "Bravo/Echo")).Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := mscfb.New(bytes.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make([]byte, 1024)
+	for f, err := r.Next(); err == nil; f, err = r.Next() {
+		if f.Name == "Charlie" {
+			if _, err := f.Read(got); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if !bytes.Equal(got[:512], fill(9000, 4)[:512]) || !bytes.Equal(got[512:1024], fill(5000, 2)[:512]) {
+		t.Error("expecting Charlie to continue into Echo")
+	}
+}
+
+func TestSize(t *testing.T) {
+	doc, err := sample().Corrupt(mscfbtest.ChainCycle("Charlie"), mscfbtest.Size("Charlie", 12000)).Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := read(t, doc)["Charlie"]
+	if len(got) != 12000 || !bytes.Equal(got[9216:9728], got[:512]) {
+		t.Error("expecting Charlie to cycle back to its first sector")
+	}
+}
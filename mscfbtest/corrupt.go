@@ -0,0 +1,446 @@
+// Copyright 2013 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mscfbtest
+
+import (
+	"encoding/binary"
+	"errors"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// Corruption is a deliberate defect applied to a compound file
+type Corruption interface {
+	String() string // name of the corruption, for reporting
+	apply(*layout) error
+}
+
+type corruption struct {
+	name string
+	fn   func(*layout) error
+}
+
+func (c corruption) String() string        { return c.name }
+func (c corruption) apply(l *layout) error { return c.fn(l) }
+
+// DirCycle points the FAT entry of the last directory sector back at the first directory sector
+func DirCycle() Corruption {
+	return corruption{"directory cycle", func(l *layout) error {
+		last := l.dirLocs[len(l.dirLocs)-1]
+		l.setFAT(last, l.dirLocs[0])
+		return nil
+	}}
+}
+
+// SiblingCycle makes the directory entry at path its own left sibling
+func SiblingCycle(path string) Corruption {
+	return corruption{"sibling cycle " + path, func(l *layout) error {
+		e, err := l.lookup(path)
+		if err != nil {
+			return err
+		}
+		l.setEntry(e.id, 68, e.id)
+		return nil
+	}}
+}
+
+// ChainCycle points the last sector of the stream at path back at its first sector
+func ChainCycle(path string) Corruption {
+	return corruption{"chain cycle " + path, func(l *layout) error {
+		e, err := l.lookup(path)
+		if err != nil {
+			return err
+		}
+		chain := l.chain(e)
+		if len(chain) == 0 {
+			return errors.New("mscfbtest: empty stream " + path)
+		}
+		l.link(e, chain[len(chain)-1], chain[0])
+		return nil
+	}}
+}
+
+// CrossLink points the first sector of stream a's chain into the chain of stream b, so that the two streams share sectors.
+// Both streams must be stored in the same place (the mini stream or regular sectors).
+func CrossLink(a, b string) Corruption {
+	return corruption{"cross link " + a + " " + b, func(l *layout) error {
+		ea, err := l.lookup(a)
+		if err != nil {
+			return err
+		}
+		eb, err := l.lookup(b)
+		if err != nil {
+			return err
+		}
+		if l.mini(ea) != l.mini(eb) {
+			return errors.New("mscfbtest: can't cross link mini and regular streams")
+		}
+		ca, cb := l.chain(ea), l.chain(eb)
+		if len(ca) == 0 || len(cb) == 0 {
+			return errors.New("mscfbtest: can't cross link empty streams")
+		}
+		l.link(ea, ca[0], cb[0])
+		return nil
+	}}
+}
+
+// DIFATCount sets the number of DIFAT sectors recorded in the header
+func DIFATCount(n uint32) Corruption {
+	return corruption{"DIFAT count " + strconv.FormatUint(uint64(n), 10), func(l *layout) error {
+		binary.LittleEndian.PutUint32(l.buf[72:], n)
+		return nil
+	}}
+}
+
+// DIFATCycle points the last DIFAT sector back at the first DIFAT sector.
+// The file must have DIFAT sectors beyond those in the header (i.e. more than 109 FAT sectors).
+func DIFATCycle() Corruption {
+	return corruption{"DIFAT cycle", func(l *layout) error {
+		if len(l.difatLocs) == 0 {
+			return errors.New("mscfbtest: no DIFAT sectors")
+		}
+		last := offset(l.ss, l.difatLocs[len(l.difatLocs)-1]) + int64(l.ss) - 4
+		binary.LittleEndian.PutUint32(l.buf[last:], l.difatLocs[0])
+		return nil
+	}}
+}
+
+// BreakLink sets the FAT (or mini FAT) entry of the first sector of the stream at path to free, so the chain ends
+// after the first sector with a free sector number rather than an end of chain marker
+func BreakLink(path string) Corruption {
+	return corruption{"break link " + path, func(l *layout) error {
+		e, err := l.lookup(path)
+		if err != nil {
+			return err
+		}
+		chain := l.chain(e)
+		if len(chain) == 0 {
+			return errors.New("mscfbtest: empty stream " + path)
+		}
+		l.link(e, chain[0], freeSect)
+		return nil
+	}}
+}
+
+// Oversize sets the size of the stream at path to one byte more than its sector chain can hold.
+// Mini streams are kept below the mini stream cutoff: if their chain already holds that much, it is ended a sector early.
+func Oversize(path string) Corruption {
+	return corruption{"oversize " + path, func(l *layout) error {
+		e, err := l.lookup(path)
+		if err != nil {
+			return err
+		}
+		chain := l.chain(e)
+		sz := uint64(l.ss)
+		if l.mini(e) {
+			sz = uint64(miniStreamSectorSize)
+			if uint64(len(chain))*sz >= uint64(miniStreamCutoffSize-1) {
+				if len(chain) < 2 {
+					return errors.New("mscfbtest: can't oversize " + path)
+				}
+				chain = chain[:len(chain)-1]
+				l.link(e, chain[len(chain)-1], endOfChain)
+			}
+		}
+		binary.LittleEndian.PutUint64(l.buf[l.entryOffset(e.id)+120:], uint64(len(chain))*sz+1)
+		return nil
+	}}
+}
+
+// Orphan ends the chain of the stream at path a sector early and shrinks its size to match, leaving the last sector
+// allocated but unreachable. The stream must keep at least one sector and stay on the same side of the mini stream cutoff.
+func Orphan(path string) Corruption {
+	return corruption{"orphan " + path, func(l *layout) error {
+		e, err := l.lookup(path)
+		if err != nil {
+			return err
+		}
+		chain := l.chain(e)
+		sz := uint64(l.ss)
+		if l.mini(e) {
+			sz = uint64(miniStreamSectorSize)
+		}
+		n := uint64(len(chain)-1) * sz
+		if len(chain) < 2 || (!l.mini(e) && n < uint64(miniStreamCutoffSize)) {
+			return errors.New("mscfbtest: can't orphan a sector of " + path)
+		}
+		l.link(e, chain[len(chain)-2], endOfChain)
+		binary.LittleEndian.PutUint64(l.buf[l.entryOffset(e.id)+120:], n)
+		return nil
+	}}
+}
+
+// SwapSiblings exchanges the left and right sibling IDs of the directory entry at path
+func SwapSiblings(path string) Corruption {
+	return corruption{"swap siblings " + path, func(l *layout) error {
+		e, err := l.lookup(path)
+		if err != nil {
+			return err
+		}
+		left, right := e.left, e.right
+		l.setEntry(e.id, 68, right)
+		l.setEntry(e.id, 72, left)
+		return nil
+	}}
+}
+
+// Truncate cuts the file short after the header and n sectors
+func Truncate(n int) Corruption {
+	return corruption{"truncate " + strconv.Itoa(n), func(l *layout) error {
+		sz := offset(l.ss, uint32(n))
+		if n < 0 || sz > int64(len(l.buf)) {
+			return errors.New("mscfbtest: file has fewer than " + strconv.Itoa(n) + " sectors")
+		}
+		l.buf = l.buf[:sz]
+		return nil
+	}}
+}
+
+// Size sets the size recorded in the directory entry at path, leaving its sector chain unchanged
+func Size(path string, n uint64) Corruption {
+	return corruption{"size " + path + " " + strconv.FormatUint(n, 10), func(l *layout) error {
+		e, err := l.lookup(path)
+		if err != nil {
+			return err
+		}
+		binary.LittleEndian.PutUint64(l.buf[l.entryOffset(e.id)+120:], n)
+		return nil
+	}}
+}
+
+// Corrupt applies corruptions, in order, to a copy of a compound file and returns the damaged copy.
+// The structures of doc are located afresh before each corruption, so corruptions can be layered.
+func Corrupt(doc []byte, cs ...Corruption) ([]byte, error) {
+	buf := append([]byte(nil), doc...)
+	for _, c := range cs {
+		l, err := parse(buf)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.apply(l); err != nil {
+			return nil, errors.New("mscfbtest: " + c.String() + ": " + strings.TrimPrefix(err.Error(), "mscfbtest: "))
+		}
+		buf = l.buf
+	}
+	return buf, nil
+}
+
+// layout locates the structures of a compound file so that they can be altered in place
+type layout struct {
+	buf         []byte
+	ss          uint32
+	major       uint16
+	fat         []uint32
+	fatLocs     []uint32 // sectors holding the FAT
+	difatLocs   []uint32 // sectors holding the DIFAT beyond the header
+	dirLocs     []uint32 // directory chain
+	miniFat     []uint32
+	miniFatLocs []uint32 // sectors holding the mini FAT
+	entries     []*entry
+	paths       map[string]*entry
+}
+
+type entry struct {
+	id                 uint32
+	name               string
+	typ                uint8
+	left, right, child uint32
+	start              uint32
+	size               uint64
+}
+
+// parse reads the header, FAT, directory and mini FAT of a compound file.
+// It tolerates damage (like cycles) so that corruptions can be layered.
+func parse(buf []byte) (*layout, error) {
+	if len(buf) < lenHeader || binary.LittleEndian.Uint64(buf) != signature {
+		return nil, errors.New("mscfbtest: not a compound file")
+	}
+	l := &layout{buf: buf, major: binary.LittleEndian.Uint16(buf[26:])}
+	switch binary.LittleEndian.Uint16(buf[30:]) {
+	case 0x0009:
+		l.ss = 512
+	case 0x000c:
+		l.ss = 4096
+	default:
+		return nil, errors.New("mscfbtest: illegal sector size")
+	}
+	per := l.ss / 4
+	numFat := binary.LittleEndian.Uint32(buf[44:])
+	difats := make([]uint32, 0, numInitialDifats)
+	for i := 0; i < numInitialDifats; i++ {
+		difats = append(difats, binary.LittleEndian.Uint32(buf[76+i*4:]))
+	}
+	seen := make(map[uint32]bool)
+	sn, num := binary.LittleEndian.Uint32(buf[68:]), binary.LittleEndian.Uint32(buf[72:])
+	for i := uint32(0); i < num && sn <= 0xFFFFFFFA && !seen[sn]; i++ {
+		seen[sn] = true
+		b, ok := l.sector(sn)
+		if !ok {
+			break
+		}
+		l.difatLocs = append(l.difatLocs, sn)
+		for j := uint32(0); j < per-1; j++ {
+			difats = append(difats, binary.LittleEndian.Uint32(b[j*4:]))
+		}
+		sn = binary.LittleEndian.Uint32(b[l.ss-4:])
+	}
+	for i := 0; i < int(numFat) && i < len(difats); i++ {
+		b, ok := l.sector(difats[i])
+		if !ok {
+			break
+		}
+		l.fatLocs = append(l.fatLocs, difats[i])
+		for j := uint32(0); j < per; j++ {
+			l.fat = append(l.fat, binary.LittleEndian.Uint32(b[j*4:]))
+		}
+	}
+	l.dirLocs = l.follow(l.fat, binary.LittleEndian.Uint32(buf[48:]))
+	if len(l.dirLocs) == 0 {
+		return nil, errors.New("mscfbtest: no directory")
+	}
+	for _, sn := range l.dirLocs {
+		b, _ := l.sector(sn)
+		for i := uint32(0); i < l.ss/dirEntrySize; i++ {
+			e := b[i*dirEntrySize:]
+			name := make([]uint16, 0, 32)
+			if nl := binary.LittleEndian.Uint16(e[64:]); nl >= 2 && nl <= 64 {
+				for j := 0; j < int(nl/2)-1; j++ {
+					name = append(name, binary.LittleEndian.Uint16(e[j*2:]))
+				}
+			}
+			l.entries = append(l.entries, &entry{
+				id:    uint32(len(l.entries)),
+				name:  string(utf16.Decode(name)),
+				typ:   e[66],
+				left:  binary.LittleEndian.Uint32(e[68:]),
+				right: binary.LittleEndian.Uint32(e[72:]),
+				child: binary.LittleEndian.Uint32(e[76:]),
+				start: binary.LittleEndian.Uint32(e[116:]),
+				size:  binary.LittleEndian.Uint64(e[120:]),
+			})
+		}
+	}
+	l.miniFatLocs = l.follow(l.fat, binary.LittleEndian.Uint32(buf[60:]))
+	for _, sn := range l.miniFatLocs {
+		b, _ := l.sector(sn)
+		for j := uint32(0); j < per; j++ {
+			l.miniFat = append(l.miniFat, binary.LittleEndian.Uint32(b[j*4:]))
+		}
+	}
+	// index entries by path
+	l.paths = make(map[string]*entry)
+	visited := make(map[uint32]bool)
+	var walk func(id uint32, prefix string)
+	walk = func(id uint32, prefix string) {
+		if id == noStream || int(id) >= len(l.entries) || visited[id] {
+			return
+		}
+		visited[id] = true
+		e := l.entries[id]
+		walk(e.left, prefix)
+		l.paths[prefix+e.name] = e
+		walk(e.child, prefix+e.name+"/")
+		walk(e.right, prefix)
+	}
+	if len(l.entries) > 0 {
+		visited[0] = true
+		walk(l.entries[0].child, "")
+	}
+	return l, nil
+}
+
+// sector returns the contents of sector sn, if it is within the file
+func (l *layout) sector(sn uint32) ([]byte, bool) {
+	off := offset(l.ss, sn)
+	if sn > 0xFFFFFFFA || off+int64(l.ss) > int64(len(l.buf)) {
+		return nil, false
+	}
+	return l.buf[off : off+int64(l.ss)], true
+}
+
+// follow returns the chain of sectors starting at sn, stopping at the end of the chain or at a cycle
+func (l *layout) follow(fat []uint32, sn uint32) []uint32 {
+	var chain []uint32
+	seen := make(map[uint32]bool)
+	for int(sn) < len(fat) && !seen[sn] {
+		seen[sn] = true
+		chain = append(chain, sn)
+		sn = fat[sn]
+	}
+	return chain
+}
+
+func (l *layout) lookup(path string) (*entry, error) {
+	e, ok := l.paths[path]
+	if !ok {
+		return nil, errors.New("mscfbtest: no entry " + path)
+	}
+	return e, nil
+}
+
+func (l *layout) mini(e *entry) bool {
+	return e.typ == stream && e.size < uint64(miniStreamCutoffSize)
+}
+
+// chain returns the sectors, or mini sectors, of a stream
+func (l *layout) chain(e *entry) []uint32 {
+	if l.mini(e) {
+		return l.follow(l.miniFat, e.start)
+	}
+	return l.follow(l.fat, e.start)
+}
+
+// link sets the next sector after sn in the FAT or mini FAT used by e
+func (l *layout) link(e *entry, sn, next uint32) {
+	if l.mini(e) {
+		l.setMiniFAT(sn, next)
+		return
+	}
+	l.setFAT(sn, next)
+}
+
+func (l *layout) setFAT(sn, v uint32) {
+	per := l.ss / 4
+	l.fat[sn] = v
+	binary.LittleEndian.PutUint32(l.buf[offset(l.ss, l.fatLocs[sn/per])+int64(sn%per*4):], v)
+}
+
+func (l *layout) setMiniFAT(sn, v uint32) {
+	per := l.ss / 4
+	l.miniFat[sn] = v
+	binary.LittleEndian.PutUint32(l.buf[offset(l.ss, l.miniFatLocs[sn/per])+int64(sn%per*4):], v)
+}
+
+func (l *layout) entryOffset(id uint32) int64 {
+	per := l.ss / dirEntrySize
+	return offset(l.ss, l.dirLocs[id/per]) + int64(id%per*dirEntrySize)
+}
+
+// setEntry writes a uint32 field (at offset off) of the directory entry with the given ID
+func (l *layout) setEntry(id uint32, off int64, v uint32) {
+	binary.LittleEndian.PutUint32(l.buf[l.entryOffset(id)+off:], v)
+	e := l.entries[id]
+	switch off {
+	case 68:
+		e.left = v
+	case 72:
+		e.right = v
+	case 76:
+		e.child = v
+	case 116:
+		e.start = v
+	}
+}
@@ -0,0 +1,113 @@
+package mscfbtest_test
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/richardlehane/mscfb"
+	"github.com/richardlehane/mscfb/mscfbtest"
+)
+
+// degrade opens a damaged file and reads every stream, returning the first error
+func degrade(doc []byte) error {
+	r, err := mscfb.New(bytes.NewReader(doc))
+	if err != nil {
+		return err
+	}
+	for f, err := r.Next(); err == nil; f, err = r.Next() {
+		if _, err := io.Copy(io.Discard, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestInject(t *testing.T) {
+	doc, err := os.ReadFile("../test/test.doc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []struct {
+		c    mscfbtest.Corruption
+		fail bool
+	}{
+		{mscfbtest.BreakLink("WordDocument"), true},
+		{mscfbtest.BreakLink("\x01CompObj"), true},
+		{mscfbtest.DirCycle(), true},
+		{mscfbtest.Oversize("Data"), true},
+		{mscfbtest.Oversize("\x01CompObj"), true},
+		{mscfbtest.Orphan("Data"), false},
+		{mscfbtest.SwapSiblings("1Table"), false},
+		{mscfbtest.Truncate(10), true},
+	} {
+		bad, err := mscfbtest.Corrupt(doc, v.c)
+		if err != nil {
+			t.Fatalf("%s: %v", v.c, err)
+		}
+		if bytes.Equal(bad, doc) {
+			t.Errorf("%s: file unchanged", v.c)
+		}
+		if err := degrade(bad); (err != nil) != v.fail {
+			t.Errorf("%s: expecting failure %v, got %v", v.c, v.fail, err)
+		}
+	}
+	if _, err := mscfbtest.Corrupt(doc, mscfbtest.DIFATCycle()); err == nil {
+		t.Error("expecting an error when there are no DIFAT sectors")
+	}
+}
+
+func TestDIFATCycle(t *testing.T) {
+	b := mscfbtest.New()
+	b.Stream("Big", make([]byte, 16000000))
+	doc, err := b.Corrupt(mscfbtest.DIFATCycle(), mscfbtest.DIFATCount(3)).Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mscfb.New(bytes.NewReader(doc)); err == nil {
+		t.Error("expecting a DIFAT cycle error")
+	}
+}
+
+func TestSectorSizes(t *testing.T) {
+	payload := bytes.Repeat([]byte("0123456789"), 500)
+	for _, v := range []struct {
+		c    mscfbtest.Corruption
+		sz   int
+		size int64
+	}{
+		{mscfbtest.Oversize("Stream"), 4090, 63*64 + 1}, // stays a mini stream
+		{mscfbtest.Oversize("Stream"), 100, 2*64 + 1},
+		{mscfbtest.Orphan("Stream"), 200, 3 * 64},
+		{mscfbtest.Orphan("Stream"), 5000, 9 * 512},
+	} {
+		b := mscfbtest.New()
+		b.Stream("Stream", payload[:v.sz])
+		doc, err := b.Corrupt(v.c).Bytes()
+		if err != nil {
+			t.Fatalf("%s %d: %v", v.c, v.sz, err)
+		}
+		r, err := mscfb.New(bytes.NewReader(doc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, err := r.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.Size != v.size {
+			t.Errorf("%s %d: expecting size %d, got %d", v.c, v.sz, v.size, f.Size)
+		}
+		if v.size%64 == 0 { // orphaned: the shortened stream still reads
+			if got, err := io.ReadAll(f); err != nil || !bytes.Equal(got, payload[:v.size]) {
+				t.Errorf("%s %d: bad read %v", v.c, v.sz, err)
+			}
+		}
+	}
+	b := mscfbtest.New()
+	b.Stream("Stream", payload[:4096])
+	if _, err := b.Corrupt(mscfbtest.Orphan("Stream")).Bytes(); err == nil {
+		t.Error("expecting an error orphaning a sector that would make a mini stream")
+	}
+}
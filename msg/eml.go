@@ -0,0 +1,291 @@
+// Copyright 2013 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package msg
+
+import (
+	"bufio"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WriteEML writes the message as an RFC 5322 / MIME message.
+//
+// Plain text and HTML bodies are written as multipart/alternative parts, with attachments referenced by
+// content ID from the HTML grouped with it in a multipart/related part. Embedded messages are written as
+// message/rfc822 attachments. Headers are generated from message properties: the original transport headers are not copied.
+// Line breaks in property values are replaced with spaces, non-ASCII text is RFC 2047 encoded and long headers are folded.
+func (m *Message) WriteEML(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	e := m.entity()
+	hdr := []string{"From", m.from()}
+	for _, rt := range []RecipientType{To, Cc} {
+		if addrs := m.addresses(rt); addrs != "" {
+			hdr = append(hdr, rt.String(), addrs)
+		}
+	}
+	hdr = append(hdr, "Subject", text(m.Subject))
+	if !m.Date.IsZero() {
+		hdr = append(hdr, "Date", m.Date.Format(time.RFC1123Z))
+	}
+	if m.MessageID != "" {
+		hdr = append(hdr, "Message-ID", text(m.MessageID))
+	}
+	hdr = append(hdr, "MIME-Version", "1.0")
+	for _, k := range []string{"Content-Type", "Content-Transfer-Encoding", "Content-Disposition"} {
+		if v := e.header.Get(k); v != "" {
+			hdr = append(hdr, k, v)
+		}
+	}
+	for i := 0; i < len(hdr); i += 2 {
+		if _, err := bw.WriteString(fold(hdr[i], hdr[i+1])); err != nil {
+			return err
+		}
+	}
+	if _, err := bw.WriteString("\r\n"); err != nil {
+		return err
+	}
+	if err := e.write(bw); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// unbreak replaces line breaks, which would end a header, with spaces
+var unbreak = strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ")
+
+// text returns a header value with line breaks removed and non-ASCII text encoded
+func text(s string) string {
+	return mime.QEncoding.Encode("utf-8", unbreak.Replace(s))
+}
+
+// fold returns a header line, folded at spaces so that lines are no longer than 78 characters where possible
+func fold(k, v string) string {
+	var sb strings.Builder
+	line := k + ":"
+	for i, w := range strings.Split(v, " ") {
+		if i > 0 && w != "" && len(line)+1+len(w) > 78 {
+			sb.WriteString(line + "\r\n")
+			line = ""
+		}
+		line += " " + w
+	}
+	sb.WriteString(line + "\r\n")
+	return sb.String()
+}
+
+func address(name, email string) string {
+	if email == "" {
+		return text(name)
+	}
+	return (&mail.Address{Name: unbreak.Replace(name), Address: unbreak.Replace(email)}).String()
+}
+
+func (m *Message) from() string {
+	return address(m.SenderName, m.SenderEmail)
+}
+
+func (m *Message) addresses(rt RecipientType) string {
+	var addrs []string
+	for _, r := range m.Recipients {
+		if r.Type == rt {
+			addrs = append(addrs, address(r.Name, r.Email))
+		}
+	}
+	return strings.Join(addrs, ", ")
+}
+
+// entity is a MIME entity: either a leaf with a body, or a multipart container of parts
+type entity struct {
+	header   textproto.MIMEHeader
+	body     func(io.Writer) error
+	boundary string
+	parts    []*entity
+}
+
+func multipartEntity(subtype string, parts ...*entity) *entity {
+	if len(parts) == 1 {
+		return parts[0]
+	}
+	e := &entity{header: make(textproto.MIMEHeader), boundary: multipart.NewWriter(nil).Boundary(), parts: parts}
+	e.header.Set("Content-Type", mime.FormatMediaType("multipart/"+subtype, map[string]string{"boundary": e.boundary}))
+	return e
+}
+
+func (e *entity) write(w io.Writer) error {
+	if e.parts == nil {
+		return e.body(w)
+	}
+	mw := multipart.NewWriter(w)
+	if err := mw.SetBoundary(e.boundary); err != nil {
+		return err
+	}
+	for _, p := range e.parts {
+		pw, err := mw.CreatePart(p.header)
+		if err != nil {
+			return err
+		}
+		if err := p.write(pw); err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
+
+func textEntity(typ, charset string, b []byte) *entity {
+	params := map[string]string{}
+	if charset != "" {
+		params["charset"] = charset
+	}
+	e := &entity{header: make(textproto.MIMEHeader)}
+	e.header.Set("Content-Type", mime.FormatMediaType(typ, params))
+	e.header.Set("Content-Transfer-Encoding", "quoted-printable")
+	e.body = func(w io.Writer) error {
+		qw := quotedprintable.NewWriter(w)
+		if _, err := qw.Write(b); err != nil {
+			return err
+		}
+		return qw.Close()
+	}
+	return e
+}
+
+func (m *Message) entity() *entity {
+	var inline, attached []*entity
+	for _, a := range m.Attachments {
+		if a.ContentID != "" && m.HTML != nil && a.Message == nil {
+			inline = append(inline, a.entity("inline"))
+		} else {
+			attached = append(attached, a.entity("attachment"))
+		}
+	}
+	var alt []*entity
+	if m.Body != "" || m.HTML == nil {
+		alt = append(alt, textEntity("text/plain", "utf-8", []byte(m.Body)))
+	}
+	if m.HTML != nil {
+		html := textEntity("text/html", charset(m.Properties.Int(tagInternetCPID)), m.HTML)
+		alt = append(alt, multipartEntity("related", append([]*entity{html}, inline...)...))
+	}
+	return multipartEntity("mixed", append([]*entity{multipartEntity("alternative", alt...)}, attached...)...)
+}
+
+func (a *Attachment) entity(disposition string) *entity {
+	e := &entity{header: make(textproto.MIMEHeader)}
+	name := a.Name()
+	if a.Message != nil {
+		if name == "" {
+			name = a.Message.Subject
+		}
+		e.header.Set("Content-Type", "message/rfc822")
+		e.header.Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": name + ".eml"}))
+		e.body = a.Message.WriteEML
+		return e
+	}
+	typ := a.MimeType
+	if typ == "" {
+		typ = mime.TypeByExtension(filepath.Ext(name))
+	}
+	if typ == "" {
+		typ = "application/octet-stream"
+	}
+	e.header.Set("Content-Type", unbreak.Replace(typ))
+	e.header.Set("Content-Transfer-Encoding", "base64")
+	if name != "" {
+		e.header.Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": name}))
+	} else {
+		e.header.Set("Content-Disposition", disposition)
+	}
+	if a.ContentID != "" {
+		e.header.Set("Content-ID", "<"+strings.Trim(unbreak.Replace(a.ContentID), "<>")+">")
+	}
+	e.body = func(w io.Writer) error {
+		lw := &lineWriter{w: w}
+		bw := base64.NewEncoder(base64.StdEncoding, lw)
+		if _, err := io.Copy(bw, a.Reader()); err != nil {
+			return err
+		}
+		if err := bw.Close(); err != nil {
+			return err
+		}
+		if lw.n > 0 {
+			_, err := w.Write([]byte("\r\n"))
+			return err
+		}
+		return nil
+	}
+	return e
+}
+
+// lineWriter breaks base64 output into 76 character lines
+type lineWriter struct {
+	w io.Writer
+	n int
+}
+
+func (l *lineWriter) Write(p []byte) (int, error) {
+	var written int
+	for len(p) > 0 {
+		sz := 76 - l.n
+		if sz > len(p) {
+			sz = len(p)
+		}
+		n, err := l.w.Write(p[:sz])
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[sz:]
+		l.n += sz
+		if l.n == 76 {
+			if _, err := l.w.Write([]byte("\r\n")); err != nil {
+				return written, err
+			}
+			l.n = 0
+		}
+	}
+	return written, nil
+}
+
+// charset returns the MIME charset name for a Windows code page, or the empty string if unknown
+func charset(cp int32) string {
+	switch {
+	case cp == 0 || cp == 65001:
+		return "utf-8"
+	case cp == 20127:
+		return "us-ascii"
+	case cp >= 28591 && cp <= 28599:
+		return "iso-8859-" + strconv.Itoa(int(cp-28590))
+	case cp >= 1250 && cp <= 1258:
+		return "windows-" + strconv.Itoa(int(cp))
+	case cp == 932:
+		return "shift_jis"
+	case cp == 936:
+		return "gb2312"
+	case cp == 949:
+		return "ks_c_5601-1987"
+	case cp == 950:
+		return "big5"
+	}
+	return ""
+}
@@ -0,0 +1,164 @@
+package msg
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+// parts reads the parts of a multipart body
+func parts(t *testing.T, ct string, body io.Reader) []*multipart.Part {
+	typ, params, err := mime.ParseMediaType(ct)
+	if err != nil || !strings.HasPrefix(typ, "multipart/") {
+		t.Fatalf("expecting multipart, got %q %v", ct, err)
+	}
+	mr := multipart.NewReader(body, params["boundary"])
+	var ret []*multipart.Part
+	for {
+		p, err := mr.NextRawPart()
+		if err == io.EOF {
+			return ret
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf, _ := io.ReadAll(p)
+		p.Header.Set("X-Body", string(buf))
+		ret = append(ret, p)
+	}
+}
+
+func TestWriteEML(t *testing.T) {
+	m := testMsg(t)
+	var buf bytes.Buffer
+	if err := m.WriteEML(&buf); err != nil {
+		t.Fatal(err)
+	}
+	em, err := mail.ReadMessage(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if em.Header.Get("Subject") != "test" || em.Header.Get("Message-Id") != m.MessageID {
+		t.Errorf("bad headers: %v", em.Header)
+	}
+	if to, err := em.Header.AddressList("To"); err != nil || len(to) != 1 || to[0].Name != "Lehane, Richard" {
+		t.Errorf("bad To header: %v %v", to, err)
+	}
+	// the HTML body is de-encapsulated from RTF and references the image by content ID
+	mixed := parts(t, em.Header.Get("Content-Type"), em.Body)
+	if len(mixed) != 2 || !strings.Contains(mixed[1].Header.Get("Content-Disposition"), "test.doc") {
+		t.Fatalf("expecting body and one attachment, got %d parts", len(mixed))
+	}
+	alt := parts(t, mixed[0].Header.Get("Content-Type"), strings.NewReader(mixed[0].Header.Get("X-Body")))
+	if len(alt) != 2 || !strings.HasPrefix(alt[0].Header.Get("Content-Type"), "text/plain") {
+		t.Fatalf("expecting text and html alternatives, got %d parts", len(alt))
+	}
+	related := parts(t, alt[1].Header.Get("Content-Type"), strings.NewReader(alt[1].Header.Get("X-Body")))
+	if len(related) != 2 {
+		t.Fatalf("expecting html and inline image, got %d parts", len(related))
+	}
+	gif, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(related[1].Header.Get("X-Body"), "\r\n", ""))
+	if err != nil || len(gif) != 2864 || related[1].Header.Get("Content-Id") != "<image001.gif@01CEE437.D50CE790>" {
+		t.Errorf("bad image attachment: %d %v %v", len(gif), err, related[1].Header)
+	}
+}
+
+func TestWriteEMLRelated(t *testing.T) {
+	m := &Message{
+		Subject:     "café",
+		SenderName:  "Sender",
+		SenderEmail: "sender@example.com",
+		Body:        "plain",
+		HTML:        []byte("<img src=\"cid:img\">"),
+		Recipients:  []*Recipient{{Type: Cc, Name: "Copy", Email: "cc@example.com"}},
+		Attachments: []*Attachment{
+			{LongName: "img.png", ContentID: "img"},
+			{LongName: "fwd", Method: 5, Message: &Message{Subject: "inner", Body: "inner body"}},
+		},
+	}
+	var buf bytes.Buffer
+	if err := m.WriteEML(&buf); err != nil {
+		t.Fatal(err)
+	}
+	em, err := mail.ReadMessage(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := new(mime.WordDecoder).DecodeHeader(em.Header.Get("Subject")); s != "café" {
+		t.Errorf("bad subject %q", s)
+	}
+	if cc, err := em.Header.AddressList("Cc"); err != nil || len(cc) != 1 || cc[0].Address != "cc@example.com" {
+		t.Errorf("bad Cc header: %v %v", cc, err)
+	}
+	mixed := parts(t, em.Header.Get("Content-Type"), em.Body)
+	if len(mixed) != 2 || mixed[1].Header.Get("Content-Type") != "message/rfc822" {
+		t.Fatalf("bad mixed parts: %d", len(mixed))
+	}
+	inner, err := mail.ReadMessage(strings.NewReader(mixed[1].Header.Get("X-Body")))
+	if err != nil || inner.Header.Get("Subject") != "inner" {
+		t.Errorf("bad embedded message: %v", err)
+	}
+	alt := parts(t, mixed[0].Header.Get("Content-Type"), strings.NewReader(mixed[0].Header.Get("X-Body")))
+	if len(alt) != 2 {
+		t.Fatalf("expecting two alternatives, got %d", len(alt))
+	}
+	related := parts(t, alt[1].Header.Get("Content-Type"), strings.NewReader(alt[1].Header.Get("X-Body")))
+	if len(related) != 2 || related[1].Header.Get("Content-Id") != "<img>" || related[1].Header.Get("Content-Type") != "image/png" {
+		t.Fatalf("bad related parts: %d", len(related))
+	}
+	html, _ := io.ReadAll(quotedprintable.NewReader(strings.NewReader(related[0].Header.Get("X-Body"))))
+	if string(html) != string(m.HTML) {
+		t.Errorf("bad html %q", html)
+	}
+}
+
+func TestWriteEMLHeaders(t *testing.T) {
+	m := &Message{
+		Subject:     "hi\r\nBcc: victim@example.com",
+		SenderName:  "Zoë\nSender",
+		SenderEmail: "sender@example.com",
+		MessageID:   "<id\r\nX-Injected: 1@example.com>",
+		Body:        "plain",
+	}
+	for i := 0; i < 20; i++ {
+		m.Recipients = append(m.Recipients, &Recipient{Type: To, Name: "Recipient Número " + string(rune('A'+i)), Email: "r@example.com"})
+	}
+	var buf bytes.Buffer
+	if err := m.WriteEML(&buf); err != nil {
+		t.Fatal(err)
+	}
+	hdr := buf.String()[:strings.Index(buf.String(), "\r\n\r\n")]
+	for _, l := range strings.Split(hdr, "\r\n") {
+		if len(l) > 78 {
+			t.Errorf("header line longer than 78 characters: %q", l)
+		}
+		for _, c := range l {
+			if c > '~' {
+				t.Errorf("non-ASCII header line: %q", l)
+				break
+			}
+		}
+	}
+	em, err := mail.ReadMessage(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if em.Header.Get("Bcc") != "" || em.Header.Get("X-Injected") != "" {
+		t.Errorf("expecting line breaks to be removed, got %v", em.Header)
+	}
+	if s, _ := new(mime.WordDecoder).DecodeHeader(em.Header.Get("Subject")); s != "hi Bcc: victim@example.com" {
+		t.Errorf("bad subject %q", s)
+	}
+	if from, err := em.Header.AddressList("From"); err != nil || len(from) != 1 || from[0].Name != "Zoë Sender" {
+		t.Errorf("bad From header: %v %v", from, err)
+	}
+	if to, err := em.Header.AddressList("To"); err != nil || len(to) != 20 || to[19].Name != "Recipient Número T" {
+		t.Errorf("bad To header: %v %v", to, err)
+	}
+}
@@ -0,0 +1,284 @@
+// Copyright 2013 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package msg reads Outlook MSG files (https://learn.microsoft.com/en-us/openspecs/exchange_server_protocols/ms-oxmsg).
+//
+// Example:
+//
+//	doc, _ := mscfb.New(file)
+//	m, err := msg.New(doc)
+//	if err != nil {
+//	  log.Fatal(err)
+//	}
+//	fmt.Println(m.Subject, m.SenderEmail)
+//	for _, a := range m.Attachments {
+//	  fmt.Println(a.Name())
+//	}
+package msg
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/richardlehane/mscfb"
+)
+
+// RecipientType is the type of a recipient: To, Cc or Bcc
+type RecipientType int
+
+const (
+	Originator RecipientType = iota
+	To
+	Cc
+	Bcc
+)
+
+func (t RecipientType) String() string {
+	switch t {
+	case To:
+		return "To"
+	case Cc:
+		return "Cc"
+	case Bcc:
+		return "Bcc"
+	}
+	return "Originator"
+}
+
+// Message is an Outlook message, or a message embedded as an attachment
+type Message struct {
+	Class       string // e.g. IPM.Note
+	Subject     string
+	SenderName  string
+	SenderEmail string
+	Date        time.Time // client submit time, or the delivery time if there is no submit time
+	MessageID   string
+	Headers     string // transport message headers, for received messages
+	Body        string
+	HTML        []byte // HTML body, or HTML de-encapsulated from the RTF body if there is no HTML body
+	RTF         []byte // compressed RTF (PR_RTF_COMPRESSED): see DecompressRTF
+	Recipients  []*Recipient
+	Attachments []*Attachment
+	Properties  Properties
+	named       map[uint16]NamedProperty
+}
+
+// Recipient is a recipient of a message
+type Recipient struct {
+	Type       RecipientType
+	Name       string
+	Email      string
+	Properties Properties
+}
+
+// Attachment is a message attachment. Embedded messages are available as Message.
+type Attachment struct {
+	Filename   string // short (8.3) filename
+	LongName   string
+	MimeType   string
+	ContentID  string
+	Method     int32 // attachment method: 1 by value, 5 embedded message, 6 OLE
+	Size       int64
+	Message    *Message
+	Properties Properties
+	data       *mscfb.File
+}
+
+// Name returns the long filename, or the short filename if there is no long filename
+func (a *Attachment) Name() string {
+	if a.LongName != "" {
+		return a.LongName
+	}
+	return a.Filename
+}
+
+// Reader returns the contents of the attachment
+func (a *Attachment) Reader() *io.SectionReader {
+	if a.data == nil {
+		return io.NewSectionReader(strings.NewReader(""), 0, 0)
+	}
+	return io.NewSectionReader(a.data, 0, a.data.Size)
+}
+
+// NamedProperty identifies a named property by its property set GUID and either a name or a numeric ID (LID)
+type NamedProperty struct {
+	GUID string
+	Name string
+	LID  uint32
+}
+
+// Named returns the named property mapped to a property ID (0x8000 and above)
+func (m *Message) Named(id uint16) (NamedProperty, bool) {
+	np, ok := m.named[id]
+	return np, ok
+}
+
+// ErrFormat is returned for compound files that aren't MSG files, or whose property streams are malformed
+var ErrFormat = errors.New("msg: bad MSG file")
+
+// Property tags
+const (
+	tagMessageClass      uint16 = 0x001A
+	tagSubject           uint16 = 0x0037
+	tagClientSubmitTime  uint16 = 0x0039
+	tagHeaders           uint16 = 0x007D
+	tagRecipientType     uint16 = 0x0C15
+	tagSenderName        uint16 = 0x0C1A
+	tagSenderEmail       uint16 = 0x0C1F
+	tagDeliveryTime      uint16 = 0x0E06
+	tagBody              uint16 = 0x1000
+	tagRTFCompressed     uint16 = 0x1009
+	tagHTML              uint16 = 0x1013
+	tagMessageID         uint16 = 0x1035
+	tagDisplayName       uint16 = 0x3001
+	tagEmailAddress      uint16 = 0x3003
+	tagAttachData        uint16 = 0x3701
+	tagAttachFilename    uint16 = 0x3704
+	tagAttachMethod      uint16 = 0x3705
+	tagAttachLongName    uint16 = 0x3707
+	tagAttachMimeTag     uint16 = 0x370E
+	tagAttachContentID   uint16 = 0x3712
+	tagSMTPAddress       uint16 = 0x39FE
+	tagSenderSMTPAddress uint16 = 0x5D01
+)
+
+const (
+	propsStream  = "__properties_version1.0"
+	nameIDs      = "__nameid_version1.0"
+	recipPrefix  = "__recip_version1.0_#"
+	attachPrefix = "__attach_version1.0_#"
+	embedded     = "__substg1.0_3701000D"
+)
+
+// New reads an MSG file
+func New(r *mscfb.Reader) (*Message, error) {
+	t := index(r)
+	if _, ok := t.files[propsStream]; !ok {
+		return nil, ErrFormat
+	}
+	named, err := t.named()
+	if err != nil {
+		return nil, err
+	}
+	return t.message("", named, 32)
+}
+
+// tree indexes directory entries by path
+type tree struct {
+	files    map[string]*mscfb.File
+	children map[string][]*mscfb.File
+}
+
+func index(r *mscfb.Reader) *tree {
+	t := &tree{files: make(map[string]*mscfb.File), children: make(map[string][]*mscfb.File)}
+	for _, f := range r.File[1:] {
+		parent := strings.Join(f.Path, "/")
+		t.children[parent] = append(t.children[parent], f)
+		t.files[join(parent, f.Name)] = f
+	}
+	return t
+}
+
+func join(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "/" + name
+}
+
+// message reads the message in the storage at path. The size of the property stream header varies by storage.
+func (t *tree) message(path string, named map[uint16]NamedProperty, hdr int) (*Message, error) {
+	props, err := t.properties(path, hdr)
+	if err != nil {
+		return nil, err
+	}
+	m := &Message{
+		Class:       props.String(tagMessageClass),
+		Subject:     props.String(tagSubject),
+		SenderName:  props.String(tagSenderName),
+		SenderEmail: props.String(tagSenderSMTPAddress),
+		Date:        props.Time(tagClientSubmitTime),
+		MessageID:   props.String(tagMessageID),
+		Headers:     props.String(tagHeaders),
+		Body:        props.String(tagBody),
+		HTML:        props.Bytes(tagHTML),
+		RTF:         props.Bytes(tagRTFCompressed),
+		Properties:  props,
+		named:       named,
+	}
+	if m.SenderEmail == "" {
+		m.SenderEmail = props.String(tagSenderEmail)
+	}
+	if m.Date.IsZero() {
+		m.Date = props.Time(tagDeliveryTime)
+	}
+	if m.HTML == nil && len(m.RTF) > 0 {
+		// a damaged or non-HTML RTF body leaves HTML empty rather than failing the message
+		if rtf, err := DecompressRTF(bytes.NewReader(m.RTF)); err == nil {
+			m.HTML, _ = HTMLFromRTF(rtf)
+		}
+	}
+	for _, c := range t.children[path] {
+		if !c.FileInfo().IsDir() {
+			continue
+		}
+		cpath := join(path, c.Name)
+		switch {
+		case strings.HasPrefix(c.Name, recipPrefix):
+			rp, err := t.properties(cpath, 8)
+			if err != nil {
+				return nil, err
+			}
+			rcp := &Recipient{
+				Type:       RecipientType(rp.Int(tagRecipientType) & 0x3),
+				Name:       rp.String(tagDisplayName),
+				Email:      rp.String(tagSMTPAddress),
+				Properties: rp,
+			}
+			if rcp.Email == "" {
+				rcp.Email = rp.String(tagEmailAddress)
+			}
+			m.Recipients = append(m.Recipients, rcp)
+		case strings.HasPrefix(c.Name, attachPrefix):
+			ap, err := t.properties(cpath, 8)
+			if err != nil {
+				return nil, err
+			}
+			a := &Attachment{
+				Filename:   ap.String(tagAttachFilename),
+				LongName:   ap.String(tagAttachLongName),
+				MimeType:   ap.String(tagAttachMimeTag),
+				ContentID:  ap.String(tagAttachContentID),
+				Method:     ap.Int(tagAttachMethod),
+				Properties: ap,
+				data:       t.files[join(cpath, stream(tagAttachData, typeBinary))],
+			}
+			if a.data != nil {
+				a.Size = a.data.Size
+			}
+			// the data object storage holds an embedded message (method 5) or an OLE object (method 6)
+			if _, ok := t.files[join(cpath, embedded)]; ok && a.Method == 5 {
+				a.Message, err = t.message(join(cpath, embedded), named, 24)
+				if err != nil {
+					return nil, err
+				}
+			}
+			m.Attachments = append(m.Attachments, a)
+		}
+	}
+	return m, nil
+}
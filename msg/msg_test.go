@@ -0,0 +1,138 @@
+package msg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/richardlehane/mscfb"
+	"github.com/richardlehane/mscfb/mscfbtest"
+)
+
+func testMsg(t *testing.T) *Message {
+	file, err := os.Open("../test/test.msg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { file.Close() })
+	r, err := mscfb.New(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := New(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestMsg(t *testing.T) {
+	m := testMsg(t)
+	if m.Class != "IPM.Note" || m.Subject != "test" || m.SenderEmail != "Richard.Lehane@records.nsw.gov.au" {
+		t.Errorf("bad message fields: %q %q %q", m.Class, m.Subject, m.SenderEmail)
+	}
+	if m.Date.Year() != 2013 || len(m.Body) == 0 || len(m.RTF) != 2679 {
+		t.Errorf("bad message date or body: %v %d %d", m.Date, len(m.Body), len(m.RTF))
+	}
+	if len(m.Recipients) != 1 || m.Recipients[0].Type != To || m.Recipients[0].Name != "Lehane, Richard" {
+		t.Errorf("bad recipients: %v", m.Recipients)
+	}
+	if len(m.Attachments) != 2 {
+		t.Fatalf("expecting 2 attachments, got %d", len(m.Attachments))
+	}
+	a := m.Attachments[1]
+	if a.Name() != "image001.gif" || a.MimeType != "image/gif" || a.ContentID == "" {
+		t.Errorf("bad attachment: %q %q %q", a.Name(), a.MimeType, a.ContentID)
+	}
+	buf, err := io.ReadAll(a.Reader())
+	if err != nil || len(buf) != 2864 || !bytes.HasPrefix(buf, []byte("GIF8")) {
+		t.Errorf("bad attachment contents: %d %v", len(buf), err)
+	}
+	if np, ok := m.Named(0x800A); !ok || np.Name != "acceptlanguage" {
+		t.Errorf("bad named property: %v", np)
+	}
+}
+
+func utf16le(s string) []byte {
+	u := utf16.Encode([]rune(s + "\x00"))
+	b := make([]byte, len(u)*2)
+	for i, v := range u {
+		binary.LittleEndian.PutUint16(b[i*2:], v)
+	}
+	return b
+}
+
+// props builds a property stream with a header of hdr bytes and an int32 property
+func props(hdr int, id uint16, v int32) []byte {
+	b := make([]byte, hdr+16)
+	binary.LittleEndian.PutUint16(b[hdr:], typeInt32)
+	binary.LittleEndian.PutUint16(b[hdr+2:], id)
+	binary.LittleEndian.PutUint32(b[hdr+8:], uint32(v))
+	return b
+}
+
+func TestEmbedded(t *testing.T) {
+	b := mscfbtest.New()
+	b.Stream(propsStream, props(32, 0x0E07, 1))
+	b.Stream(stream(tagSubject, typeUnicode), utf16le("outer"))
+	b.Stream(stream(tagBody, typeString8), []byte("caf\xe9\x00"))
+	b.Storage(nameIDs)
+	att := b.Storage(attachPrefix + "00000000")
+	att.Stream(propsStream, props(8, tagAttachMethod, 5))
+	att.Stream(stream(tagDisplayName, typeUnicode), utf16le("inner message"))
+	inner := att.Storage(embedded)
+	inner.Stream(propsStream, props(24, 0x0E07, 1))
+	inner.Stream(stream(tagSubject, typeUnicode), utf16le("inner"))
+	inner.Storage(recipPrefix+"00000000").
+		Stream(propsStream, props(8, tagRecipientType, 2)).
+		Stream(stream(tagEmailAddress, typeUnicode), utf16le("cc@example.com"))
+	doc, err := b.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := mscfb.New(bytes.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := New(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Subject != "outer" || m.Body != "café" || len(m.Attachments) != 1 {
+		t.Fatalf("bad outer message: %q %q %d", m.Subject, m.Body, len(m.Attachments))
+	}
+	a := m.Attachments[0]
+	if a.Method != 5 || a.Message == nil || a.Message.Subject != "inner" {
+		t.Fatalf("bad embedded message: %d %v", a.Method, a.Message)
+	}
+	if rs := a.Message.Recipients; len(rs) != 1 || rs[0].Type != Cc || rs[0].Email != "cc@example.com" {
+		t.Errorf("bad embedded recipients: %v", rs)
+	}
+}
+
+func TestOLEAttachment(t *testing.T) {
+	b := mscfbtest.New()
+	b.Stream(propsStream, props(32, 0x0E07, 1))
+	b.Storage(nameIDs)
+	att := b.Storage(attachPrefix + "00000000")
+	att.Stream(propsStream, props(8, tagAttachMethod, 6))
+	att.Storage(embedded).Stream("\x01Ole10Native", []byte{3, 0, 0, 0, 'o', 'l', 'e'})
+	doc, err := b.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := mscfb.New(bytes.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := New(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Attachments) != 1 || m.Attachments[0].Method != 6 || m.Attachments[0].Message != nil {
+		t.Errorf("bad OLE attachment: %v", m.Attachments)
+	}
+}
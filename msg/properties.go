@@ -0,0 +1,268 @@
+// Copyright 2013 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package msg
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/richardlehane/mscfb/internal/ole"
+)
+
+// Property types
+const (
+	typeInt16    uint16 = 0x0002
+	typeInt32    uint16 = 0x0003
+	typeFloat    uint16 = 0x0004
+	typeDouble   uint16 = 0x0005
+	typeCurrency uint16 = 0x0006
+	typeAppTime  uint16 = 0x0007
+	typeError    uint16 = 0x000A
+	typeBoolean  uint16 = 0x000B
+	typeInt64    uint16 = 0x0014
+	typeString8  uint16 = 0x001E
+	typeUnicode  uint16 = 0x001F
+	typeTime     uint16 = 0x0040
+	typeGUID     uint16 = 0x0048
+	typeBinary   uint16 = 0x0102
+	typeMulti    uint16 = 0x1000
+)
+
+const (
+	tagInternetCPID uint16 = 0x3FDE
+	tagCodePage     uint16 = 0x3FFD
+)
+
+// Property is a MAPI property.
+// Values are int16, int32, float32, float64, int64, bool, time.Time, string or []byte depending on the type.
+type Property struct {
+	Type  uint16
+	Value interface{}
+}
+
+// Properties are MAPI properties keyed by property ID
+type Properties map[uint16]Property
+
+// String returns a string property, or the empty string if it isn't present
+func (p Properties) String(id uint16) string {
+	s, _ := p[id].Value.(string)
+	return s
+}
+
+// Bytes returns a binary or string property
+func (p Properties) Bytes(id uint16) []byte {
+	switch v := p[id].Value.(type) {
+	case []byte:
+		return v
+	case string:
+		return []byte(v)
+	}
+	return nil
+}
+
+// Int returns an integer property
+func (p Properties) Int(id uint16) int32 {
+	switch v := p[id].Value.(type) {
+	case int16:
+		return int32(v)
+	case int32:
+		return v
+	}
+	return 0
+}
+
+// Bool returns a boolean property
+func (p Properties) Bool(id uint16) bool {
+	b, _ := p[id].Value.(bool)
+	return b
+}
+
+// Time returns a time property
+func (p Properties) Time(id uint16) time.Time {
+	t, _ := p[id].Value.(time.Time)
+	return t
+}
+
+// properties reads the fixed length properties from the property stream, and variable length properties from
+// their own streams, of the storage at path
+func (t *tree) properties(path string, hdr int) (Properties, error) {
+	ps, ok := t.files[join(path, propsStream)]
+	if !ok {
+		return nil, ErrFormat
+	}
+	buf, err := ole.ReadAll(ps)
+	if err != nil {
+		return nil, err
+	}
+	if len(buf) < hdr {
+		return nil, ErrFormat
+	}
+	props := make(Properties)
+	for b := buf[hdr:]; len(b) >= 16; b = b[16:] {
+		typ, id := binary.LittleEndian.Uint16(b), binary.LittleEndian.Uint16(b[2:])
+		v := b[8:16]
+		var val interface{}
+		switch typ {
+		case typeInt16:
+			val = int16(binary.LittleEndian.Uint16(v))
+		case typeInt32, typeError:
+			val = int32(binary.LittleEndian.Uint32(v))
+		case typeFloat:
+			val = math.Float32frombits(binary.LittleEndian.Uint32(v))
+		case typeDouble, typeAppTime:
+			val = math.Float64frombits(binary.LittleEndian.Uint64(v))
+		case typeCurrency, typeInt64:
+			val = int64(binary.LittleEndian.Uint64(v))
+		case typeBoolean:
+			val = v[0] != 0
+		case typeTime:
+			val = ole.Filetime(binary.LittleEndian.Uint64(v))
+		default:
+			continue
+		}
+		props[id] = Property{typ, val}
+	}
+	cp := props.Int(tagCodePage)
+	if cpid := props.Int(tagInternetCPID); cp == 0 {
+		cp = cpid
+	}
+	for _, f := range t.children[path] {
+		id, typ, ok := substg(f.Name)
+		if !ok || f.FileInfo().IsDir() || typ&typeMulti != 0 {
+			continue
+		}
+		// attachment data is read on demand
+		if id == tagAttachData && typ == typeBinary {
+			continue
+		}
+		b, err := ole.ReadAll(f)
+		if err != nil {
+			return nil, err
+		}
+		var val interface{}
+		switch typ {
+		case typeUnicode:
+			val = unicode(b)
+		case typeString8:
+			val = decode(b, cp)
+		case typeBinary:
+			val = b
+		case typeGUID:
+			if len(b) < 16 {
+				return nil, ErrFormat
+			}
+			val = guid(b)
+		default:
+			continue
+		}
+		props[id] = Property{typ, val}
+	}
+	return props, nil
+}
+
+// stream returns the name of the stream holding a variable length property
+func stream(id, typ uint16) string {
+	return fmt.Sprintf("__substg1.0_%04X%04X", id, typ)
+}
+
+// substg parses the ID and type from a property stream name
+func substg(name string) (uint16, uint16, bool) {
+	if len(name) != 20 || !strings.HasPrefix(name, "__substg1.0_") {
+		return 0, 0, false
+	}
+	tag, err := strconv.ParseUint(name[12:], 16, 32)
+	if err != nil {
+		return 0, 0, false
+	}
+	return uint16(tag >> 16), uint16(tag), true
+}
+
+// named reads the named property mappings
+func (t *tree) named() (map[uint16]NamedProperty, error) {
+	ret := make(map[uint16]NamedProperty)
+	guids, err := t.bytes(join(nameIDs, stream(0x0002, typeBinary)))
+	if err != nil {
+		return nil, err
+	}
+	entries, err := t.bytes(join(nameIDs, stream(0x0003, typeBinary)))
+	if err != nil {
+		return nil, err
+	}
+	strs, err := t.bytes(join(nameIDs, stream(0x0004, typeBinary)))
+	if err != nil {
+		return nil, err
+	}
+	for e := entries; len(e) >= 8; e = e[8:] {
+		nid, ik := binary.LittleEndian.Uint32(e), binary.LittleEndian.Uint32(e[4:])
+		np := NamedProperty{}
+		switch gi := int(ik>>1) & 0x7FFF; gi {
+		case 0:
+		case 1:
+			np.GUID = "{00020328-0000-0000-C000-000000000046}" // PS_MAPI
+		case 2:
+			np.GUID = "{00020329-0000-0000-C000-000000000046}" // PS_PUBLIC_STRINGS
+		default:
+			if (gi-3)*16+16 > len(guids) {
+				return nil, ErrFormat
+			}
+			np.GUID = guid(guids[(gi-3)*16:])
+		}
+		if ik&1 == 0 {
+			np.LID = nid
+		} else {
+			if uint64(nid)+4 > uint64(len(strs)) {
+				return nil, ErrFormat
+			}
+			l := binary.LittleEndian.Uint32(strs[nid:])
+			if uint64(nid)+4+uint64(l) > uint64(len(strs)) {
+				return nil, ErrFormat
+			}
+			np.Name = unicode(strs[nid+4 : nid+4+l])
+		}
+		ret[0x8000+uint16(ik>>16)] = np
+	}
+	return ret, nil
+}
+
+// bytes reads the stream at path, returning nil if it doesn't exist
+func (t *tree) bytes(path string) ([]byte, error) {
+	f, ok := t.files[path]
+	if !ok {
+		return nil, nil
+	}
+	return ole.ReadAll(f)
+}
+
+// unicode decodes UTF-16LE strings, dropping trailing nulls
+func unicode(b []byte) string {
+	return strings.TrimRight(ole.UTF16(b), "\x00")
+}
+
+// decode 8-bit strings, dropping trailing nulls
+func decode(b []byte, codePage int32) string {
+	for len(b) > 0 && b[len(b)-1] == 0 {
+		b = b[:len(b)-1]
+	}
+	return ole.Decode(b, int(codePage))
+}
+
+func guid(b []byte) string {
+	return fmt.Sprintf("{%08X-%04X-%04X-%X-%X}", binary.LittleEndian.Uint32(b), binary.LittleEndian.Uint16(b[4:]),
+		binary.LittleEndian.Uint16(b[6:]), b[8:10], b[10:16])
+}
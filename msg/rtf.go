@@ -0,0 +1,287 @@
+// Copyright 2013 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package msg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"strconv"
+)
+
+var (
+	// ErrRTF is returned for malformed compressed RTF, including CRC mismatches
+	ErrRTF = errors.New("msg: bad compressed RTF")
+	// ErrNotEncapsulated is returned by HTMLFromRTF when the RTF doesn't encapsulate HTML
+	ErrNotEncapsulated = errors.New("msg: RTF does not encapsulate HTML")
+)
+
+const (
+	rtfCompressed   = 0x75465A4C // "LZFu"
+	rtfUncompressed = 0x414C454D // "MELA"
+)
+
+// rtfDict is the initial contents of the compression dictionary (MS-OXRTFCP 2.1.2.1)
+const rtfDict = "{\\rtf1\\ansi\\mac\\deff0\\deftab720{\\fonttbl;}{\\f0\\fnil \\froman \\fswiss \\fmodern \\fscript \\fdecor MS Sans SerifSymbolArialTimes New RomanCourier{\\colortbl\\red0\\green0\\blue0\r\n\\par \\pard\\plain\\f0\\fs20\\b\\i\\u\\tab\\tx"
+
+// DecompressRTF reads compressed RTF (MS-OXRTFCP), such as the PR_RTF_COMPRESSED stream
+// __substg1.0_10090102, and returns the RTF.
+func DecompressRTF(r io.Reader) ([]byte, error) {
+	hdr := make([]byte, 16)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		return nil, ErrRTF
+	}
+	compSize, rawSize := binary.LittleEndian.Uint32(hdr), binary.LittleEndian.Uint32(hdr[4:])
+	compType, crc := binary.LittleEndian.Uint32(hdr[8:]), binary.LittleEndian.Uint32(hdr[12:])
+	if compSize < 12 {
+		return nil, ErrRTF
+	}
+	// read through a limited reader rather than trusting the declared size: streams shorter than it are tolerated,
+	// and decompression stops at the end of input
+	in, err := io.ReadAll(io.LimitReader(r, int64(compSize-12)))
+	if err != nil {
+		return nil, ErrRTF
+	}
+	switch compType {
+	case rtfUncompressed:
+		if uint32(len(in)) < rawSize {
+			return nil, ErrRTF
+		}
+		return in[:rawSize], nil
+	case rtfCompressed:
+	default:
+		return nil, ErrRTF
+	}
+	// CRC32 with no pre or post conditioning (MS-OXRTFCP 2.1.3.2)
+	if ^crc32.Update(0xFFFFFFFF, crc32.IEEETable, in) != crc {
+		return nil, ErrRTF
+	}
+	dict := make([]byte, 4096)
+	copy(dict, rtfDict)
+	wpos := len(rtfDict)
+	// each two byte reference expands to at most 17 bytes
+	sz := uint64(len(in)) / 2 * 17
+	if uint64(rawSize) < sz {
+		sz = uint64(rawSize)
+	}
+	out := make([]byte, 0, sz)
+	for i := 0; i < len(in); {
+		control := in[i]
+		i++
+		for bit := 0; bit < 8 && i < len(in); bit++ {
+			if control&(1<<bit) == 0 {
+				out = append(out, in[i])
+				dict[wpos] = in[i]
+				wpos = (wpos + 1) % 4096
+				i++
+				continue
+			}
+			if i+2 > len(in) {
+				return nil, ErrRTF
+			}
+			ref := int(binary.BigEndian.Uint16(in[i:]))
+			i += 2
+			offset, length := ref>>4, ref&0xF+2
+			if offset == wpos {
+				return trim(out, rawSize), nil
+			}
+			for j := 0; j < length; j++ {
+				c := dict[(offset+j)%4096]
+				out = append(out, c)
+				dict[wpos] = c
+				wpos = (wpos + 1) % 4096
+			}
+		}
+	}
+	return trim(out, rawSize), nil
+}
+
+func trim(b []byte, sz uint32) []byte {
+	if uint32(len(b)) > sz {
+		return b[:sz]
+	}
+	return b
+}
+
+// rtfIgnore are destinations whose text isn't part of the encapsulated HTML
+var rtfIgnore = map[string]bool{
+	"fonttbl":    true,
+	"colortbl":   true,
+	"stylesheet": true,
+	"info":       true,
+	"pict":       true,
+	"object":     true,
+	"header":     true,
+	"footer":     true,
+	"listtable":  true,
+	"rsidtbl":    true,
+	"generator":  true,
+	"mhtmltag":   true,
+}
+
+// HTMLFromRTF de-encapsulates HTML from RTF (MS-OXRTFEX). Text and \*\htmltag destinations are copied to the
+// output, except where suppressed by \htmlrtf. Characters given by \u are written as HTML character references;
+// \'hh escapes are written as raw bytes in the RTF's code page.
+func HTMLFromRTF(rtf []byte) ([]byte, error) {
+	type state struct {
+		ignore   bool
+		suppress bool
+		skip     int // \uc: number of fallback characters after \u
+	}
+	var (
+		out       bytes.Buffer
+		st        = state{skip: 1}
+		stack     []state
+		html      bool
+		dest      bool // the group has just started: the next control word may name its destination
+		star      bool // \* seen at the start of the group
+		fallbacks int  // fallback characters still to skip
+	)
+	emit := func(b ...byte) {
+		if fallbacks > 0 {
+			fallbacks--
+			return
+		}
+		if !st.ignore && !st.suppress {
+			out.Write(b)
+		}
+	}
+	for i := 0; i < len(rtf); {
+		c := rtf[i]
+		switch c {
+		case '{':
+			stack = append(stack, st)
+			dest, star = true, false
+			i++
+			continue
+		case '}':
+			if len(stack) > 0 {
+				st, stack = stack[len(stack)-1], stack[:len(stack)-1]
+			}
+			dest, star = false, false
+			i++
+			continue
+		case '\r', '\n':
+			i++
+			continue
+		case '\\':
+		default:
+			emit(c)
+			dest = false
+			i++
+			continue
+		}
+		// control symbols
+		if i+1 >= len(rtf) {
+			break
+		}
+		switch n := rtf[i+1]; {
+		case n == '*':
+			star = true
+			i += 2
+			continue
+		case n == '\'':
+			if i+4 > len(rtf) {
+				return nil, ErrNotEncapsulated
+			}
+			v, err := strconv.ParseUint(string(rtf[i+2:i+4]), 16, 8)
+			if err == nil {
+				emit(byte(v))
+			}
+			i += 4
+			dest = false
+			continue
+		case n == '\\' || n == '{' || n == '}':
+			emit(n)
+			i += 2
+			dest = false
+			continue
+		case n == '~':
+			emit([]byte("&nbsp;")...)
+			i += 2
+			continue
+		case n == '_':
+			emit('-')
+			i += 2
+			continue
+		case n == '\r' || n == '\n':
+			emit('\r', '\n')
+			i += 2
+			continue
+		case n < 'a' || n > 'z':
+			// other control symbols (e.g. \- optional hyphen) have no output
+			i += 2
+			continue
+		}
+		// control words
+		j := i + 1
+		for j < len(rtf) && rtf[j] >= 'a' && rtf[j] <= 'z' {
+			j++
+		}
+		word := string(rtf[i+1 : j])
+		k := j
+		if k < len(rtf) && rtf[k] == '-' {
+			k++
+		}
+		for k < len(rtf) && rtf[k] >= '0' && rtf[k] <= '9' {
+			k++
+		}
+		param, hasParam := 0, k > j
+		if hasParam {
+			param, _ = strconv.Atoi(string(rtf[j:k]))
+		}
+		if k < len(rtf) && rtf[k] == ' ' {
+			k++
+		}
+		i = k
+		if dest {
+			dest = false
+			switch {
+			case word == "htmltag":
+				st.ignore = false
+				continue
+			case rtfIgnore[word] || star:
+				st.ignore = true
+				continue
+			}
+		}
+		switch word {
+		case "fromhtml":
+			html = true
+		case "fromtext":
+			return nil, ErrNotEncapsulated
+		case "htmlrtf":
+			st.suppress = !hasParam || param != 0
+		case "par", "line":
+			emit('\r', '\n')
+		case "tab":
+			emit('\t')
+		case "uc":
+			st.skip = param
+		case "u":
+			if param < 0 {
+				param += 65536
+			}
+			emit([]byte("&#" + strconv.Itoa(param) + ";")...)
+			fallbacks = st.skip
+		}
+	}
+	if !html {
+		return nil, ErrNotEncapsulated
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,71 @@
+package msg
+
+import (
+	"bytes"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestDecompressRTF(t *testing.T) {
+	// examples from MS-OXRTFCP 3.1
+	for _, v := range []struct {
+		compressed []byte
+		expect     string
+	}{
+		{
+			[]byte{0x2d, 0x00, 0x00, 0x00, 0x2b, 0x00, 0x00, 0x00, 0x4c, 0x5a, 0x46, 0x75, 0xf1, 0xc5, 0xc7, 0xa7, 0x03, 0x00,
+				0x0a, 0x00, 0x72, 0x63, 0x70, 0x67, 0x31, 0x32, 0x35, 0x42, 0x32, 0x0a, 0xf3, 0x20, 0x68, 0x65, 0x6c, 0x09,
+				0x00, 0x20, 0x62, 0x77, 0x05, 0xb0, 0x6c, 0x64, 0x7d, 0x0a, 0x80, 0x0f, 0xa0},
+			"{\\rtf1\\ansi\\ansicpg1252\\pard hello world}\r\n",
+		},
+		{
+			[]byte{0x1a, 0x00, 0x00, 0x00, 0x1c, 0x00, 0x00, 0x00, 0x4c, 0x5a, 0x46, 0x75, 0xe2, 0xd4, 0x4b, 0x51, 0x41, 0x00,
+				0x04, 0x20, 0x57, 0x58, 0x59, 0x5a, 0x0d, 0x6e, 0x7d, 0x01, 0x0e, 0xb0},
+			"{\\rtf1 WXYZWXYZWXYZWXYZWXYZ}",
+		},
+		{
+			append([]byte{0x10, 0x00, 0x00, 0x00, 0x04, 0x00, 0x00, 0x00, 0x4d, 0x45, 0x4c, 0x41, 0, 0, 0, 0}, "{\\rtf"...),
+			"{\\rt",
+		},
+	} {
+		got, err := DecompressRTF(bytes.NewReader(v.compressed))
+		if err != nil || string(got) != v.expect {
+			t.Errorf("expecting %q, got %q (%v)", v.expect, got, err)
+		}
+	}
+	bad := []byte{0x2d, 0x00, 0x00, 0x00, 0x2b, 0x00, 0x00, 0x00, 0x4c, 0x5a, 0x46, 0x75, 0xf1, 0xc5, 0xc7, 0xa7, 0x03, 0x01}
+	if _, err := DecompressRTF(bytes.NewReader(bad)); err != ErrRTF {
+		t.Errorf("expecting CRC error, got %v", err)
+	}
+	// sizes in the header larger than the stream
+	huge := []byte{0xf0, 0xff, 0xff, 0xff, 0xf0, 0xff, 0xff, 0xff, 0x4c, 0x5a, 0x46, 0x75, 0xe2, 0xd4, 0x4b, 0x51, 0x41, 0x00,
+		0x04, 0x20, 0x57, 0x58, 0x59, 0x5a, 0x0d, 0x6e, 0x7d, 0x01, 0x0e, 0xb0}
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	got, err := DecompressRTF(bytes.NewReader(huge))
+	runtime.ReadMemStats(&after)
+	if err != nil || string(got) != "{\\rtf1 WXYZWXYZWXYZWXYZWXYZ}" {
+		t.Errorf("bad decompression with oversized header: %q %v", got, err)
+	}
+	if a := after.TotalAlloc - before.TotalAlloc; a > 1<<20 {
+		t.Errorf("expecting allocation bounded by the input, got %d bytes", a)
+	}
+}
+
+func TestHTMLFromRTF(t *testing.T) {
+	rtf := "{\\rtf1\\ansi\\ansicpg1252\\fromhtml1 \\deff0{\\fonttbl{\\f0\\fswiss Arial;}}\r\n" +
+		"{\\*\\htmltag64 <p>}\\htmlrtf {\\b\\htmlrtf0 caf\\'e9 \\{x\\}\\htmlrtf }\\htmlrtf0 \\u8364?\r\n" +
+		"{\\*\\mhtmltag84 <img src=\"x.gif\">}{\\*\\htmltag84 <img src=\"cid:x\">}\\par\r\n{\\*\\htmltag72 </p>}}"
+	got, err := HTMLFromRTF([]byte(rtf))
+	if expect := "<p>caf\xe9 {x}&#8364;<img src=\"cid:x\">\r\n</p>"; err != nil || string(got) != expect {
+		t.Errorf("expecting %q, got %q (%v)", expect, got, err)
+	}
+	if _, err := HTMLFromRTF([]byte("{\\rtf1\\ansi hello}")); err != ErrNotEncapsulated {
+		t.Errorf("expecting ErrNotEncapsulated, got %v", err)
+	}
+	m := testMsg(t)
+	if !strings.Contains(string(m.HTML), "src=\"cid:image001.gif@01CEE437.D50CE790\"") {
+		t.Error("expecting HTML body de-encapsulated from RTF")
+	}
+}
@@ -0,0 +1,433 @@
+// Copyright 2013 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package msi reads Windows Installer databases (MSI, MSM, MSP and MST files).
+//
+// Example:
+//
+//	doc, _ := mscfb.New(file)
+//	db, err := msi.New(doc)
+//	if err != nil {
+//	  log.Fatal(err)
+//	}
+//	fmt.Println(db.Property("ProductName"), db.Property("ProductVersion"))
+//	rows, _ := db.Rows("File")
+//	for _, row := range rows {
+//	  fmt.Println(row.String("FileName"), row.Int("FileSize"))
+//	}
+package msi
+
+import (
+	"encoding/binary"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/richardlehane/mscfb"
+	"github.com/richardlehane/mscfb/internal/ole"
+)
+
+// ErrFormat is returned for compound files that aren't Windows Installer databases, or whose tables are malformed
+var ErrFormat = errors.New("msi: bad MSI database")
+
+// ErrNoTable is returned by Table and Rows for tables that aren't in the database
+var ErrNoTable = errors.New("msi: no such table")
+
+// DecodeName decodes a compound file stream name that uses the MSI name compression, in which pairs of characters from
+// [0-9A-Za-z._] are packed into a single character in the range U+3800 to U+4840. It reports whether the name is a table
+// stream (prefixed with U+4840).
+func DecodeName(name string) (string, bool) {
+	u := utf16.Encode([]rune(name))
+	var table bool
+	if len(u) > 0 && u[0] == 0x4840 {
+		table = true
+		u = u[1:]
+	}
+	out := make([]rune, 0, len(u)*2)
+	for _, c := range u {
+		switch {
+		case c >= 0x3800 && c < 0x4800:
+			c -= 0x3800
+			out = append(out, charset(c&0x3F), charset((c>>6)&0x3F))
+		case c >= 0x4800 && c < 0x4840:
+			out = append(out, charset(c-0x4800))
+		default:
+			out = append(out, rune(c))
+		}
+	}
+	return string(out), table
+}
+
+func charset(c uint16) rune {
+	switch {
+	case c < 10:
+		return rune(c) + '0'
+	case c < 36:
+		return rune(c) - 10 + 'A'
+	case c < 62:
+		return rune(c) - 36 + 'a'
+	case c == 62:
+		return '.'
+	}
+	return '_'
+}
+
+// Column type bits
+const (
+	typeValid    uint16 = 0x0100
+	typeString   uint16 = 0x0800
+	typeNullable uint16 = 0x1000
+	typeKey      uint16 = 0x2000
+)
+
+// Column describes a table column
+type Column struct {
+	Name     string
+	Type     uint16 // column type from the _Columns table
+	Key      bool
+	Nullable bool
+}
+
+// Text reports whether the column holds strings
+func (c Column) Text() bool {
+	return c.Type&typeString != 0 && !c.Binary()
+}
+
+// Binary reports whether the column holds streams. Values of binary columns are the names of the streams holding the data.
+func (c Column) Binary() bool {
+	return c.Type&^typeNullable == typeString|typeValid
+}
+
+func (c Column) size(strRef int) int {
+	switch {
+	case c.Binary():
+		return 2
+	case c.Type&typeString != 0:
+		return strRef
+	case c.Type&0xFF <= 2:
+		return 2
+	}
+	return 4
+}
+
+// Table is the schema of a table
+type Table struct {
+	Name    string
+	Columns []Column
+}
+
+// Row is a row in a table. Values are string, int32, or nil for null values.
+type Row struct {
+	table  *Table
+	Values []interface{}
+}
+
+func (r Row) value(col string) interface{} {
+	for i, c := range r.table.Columns {
+		if c.Name == col {
+			return r.Values[i]
+		}
+	}
+	return nil
+}
+
+// String returns the value of a string column, or the empty string if the column is null or not a string column
+func (r Row) String(col string) string {
+	s, _ := r.value(col).(string)
+	return s
+}
+
+// Int returns the value of an integer column, or 0 if the column is null or not an integer column
+func (r Row) Int(col string) int32 {
+	i, _ := r.value(col).(int32)
+	return i
+}
+
+// Database is a Windows Installer database
+type Database struct {
+	CodePage int
+	strings  []string // string pool, indexed by string ID. ID 0 is the null string.
+	strRef   int      // size of string references in tables: 2 or 3 bytes
+	tables   map[string]*Table
+	streams  map[string]*mscfb.File // table streams
+	files    map[string]*mscfb.File // other streams, such as those holding binary column data
+}
+
+// New reads a Windows Installer database
+func New(r *mscfb.Reader) (*Database, error) {
+	d := &Database{streams: make(map[string]*mscfb.File), files: make(map[string]*mscfb.File)}
+	for _, f := range r.File[1:] {
+		if f.FileInfo().IsDir() || len(f.Path) > 0 {
+			continue
+		}
+		name, table := DecodeName(f.Name)
+		if table {
+			d.streams[name] = f
+		} else {
+			d.files[name] = f
+		}
+	}
+	if err := d.loadStrings(); err != nil {
+		return nil, err
+	}
+	if err := d.loadTables(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *Database) loadStrings() error {
+	pf, df := d.streams["_StringPool"], d.streams["_StringData"]
+	if pf == nil || df == nil {
+		return ErrFormat
+	}
+	pool, err := ole.ReadAll(pf)
+	if err != nil {
+		return err
+	}
+	data, err := ole.ReadAll(df)
+	if err != nil {
+		return err
+	}
+	if len(pool) < 4 {
+		return ErrFormat
+	}
+	hdr := binary.LittleEndian.Uint32(pool)
+	d.CodePage = int(hdr & 0xFFFF)
+	d.strRef = 2
+	if hdr&0x80000000 != 0 {
+		d.strRef = 3
+	}
+	d.strings = []string{""}
+	var off int
+	for p := pool[4:]; len(p) >= 4; {
+		l, refs := int(binary.LittleEndian.Uint16(p)), binary.LittleEndian.Uint16(p[2:])
+		p = p[4:]
+		switch {
+		case l == 0 && refs == 0:
+			d.strings = append(d.strings, "")
+			continue
+		case l == 0:
+			// strings of 64K or more have a null length entry holding the reference count, followed by the low and high words of the length
+			if len(p) < 4 {
+				return ErrFormat
+			}
+			l = int(binary.LittleEndian.Uint16(p[2:]))<<16 | int(binary.LittleEndian.Uint16(p))
+			p = p[4:]
+		}
+		if l > len(data)-off {
+			return ErrFormat
+		}
+		d.strings = append(d.strings, ole.Decode(data[off:off+l], d.CodePage))
+		off += l
+	}
+	return nil
+}
+
+func (d *Database) str(id int) (string, error) {
+	if id < 0 || id >= len(d.strings) {
+		return "", ErrFormat
+	}
+	return d.strings[id], nil
+}
+
+// columnsTable is the fixed schema of the _Columns table
+var columnsTable = &Table{Name: "_Columns", Columns: []Column{
+	{Name: "Table", Type: typeValid | typeString | typeKey | 64, Key: true},
+	{Name: "Number", Type: typeValid | typeKey | 2, Key: true},
+	{Name: "Name", Type: typeValid | typeString | 64},
+	{Name: "Type", Type: typeValid | 2},
+}}
+
+func (d *Database) loadTables() error {
+	d.tables = map[string]*Table{"_Columns": columnsTable}
+	if tf := d.streams["_Tables"]; tf != nil {
+		b, err := ole.ReadAll(tf)
+		if err != nil {
+			return err
+		}
+		for ; len(b) >= d.strRef; b = b[d.strRef:] {
+			name, err := d.str(d.ref(b))
+			if err != nil {
+				return err
+			}
+			d.tables[name] = &Table{Name: name}
+		}
+	}
+	rows, err := d.rows(columnsTable)
+	if err != nil {
+		return err
+	}
+	// sort by table and column number as rows are ordered by their keys' string IDs, not the strings
+	sort.SliceStable(rows, func(i, j int) bool {
+		ti, tj := rows[i].String("Table"), rows[j].String("Table")
+		if ti != tj {
+			return ti < tj
+		}
+		return rows[i].Int("Number") < rows[j].Int("Number")
+	})
+	for _, row := range rows {
+		name := row.String("Table")
+		t, ok := d.tables[name]
+		if !ok {
+			t = &Table{Name: name}
+			d.tables[name] = t
+		}
+		typ := uint16(row.Int("Type"))
+		t.Columns = append(t.Columns, Column{
+			Name:     row.String("Name"),
+			Type:     typ,
+			Key:      typ&typeKey != 0,
+			Nullable: typ&typeNullable != 0,
+		})
+	}
+	return nil
+}
+
+func (d *Database) ref(b []byte) int {
+	if d.strRef == 3 {
+		return int(b[0]) | int(b[1])<<8 | int(b[2])<<16
+	}
+	return int(binary.LittleEndian.Uint16(b))
+}
+
+// Tables returns the names of the tables in the database
+func (d *Database) Tables() []string {
+	ret := make([]string, 0, len(d.tables))
+	for n := range d.tables {
+		ret = append(ret, n)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// Table returns the schema of a table
+func (d *Database) Table(name string) (*Table, error) {
+	t, ok := d.tables[name]
+	if !ok {
+		return nil, ErrNoTable
+	}
+	return t, nil
+}
+
+// Rows returns the rows of a table
+func (d *Database) Rows(name string) ([]Row, error) {
+	t, err := d.Table(name)
+	if err != nil {
+		return nil, err
+	}
+	return d.rows(t)
+}
+
+// tables are stored column by column: the values of the first column for every row, then the second column, and so on
+func (d *Database) rows(t *Table) ([]Row, error) {
+	f := d.streams[t.Name]
+	if f == nil || len(t.Columns) == 0 {
+		// tables without rows have no stream
+		return nil, nil
+	}
+	b, err := ole.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	var rowSize int
+	for _, c := range t.Columns {
+		rowSize += c.size(d.strRef)
+	}
+	n := len(b) / rowSize
+	rows := make([]Row, n)
+	for i := range rows {
+		rows[i] = Row{table: t, Values: make([]interface{}, len(t.Columns))}
+	}
+	var off int
+	for ci, c := range t.Columns {
+		sz := c.size(d.strRef)
+		for i := range rows {
+			v := b[off+i*sz:]
+			switch {
+			case c.Binary():
+				if binary.LittleEndian.Uint16(v) != 0 {
+					rows[i].Values[ci] = ""
+				}
+			case c.Type&typeString != 0:
+				id := d.ref(v)
+				if id == 0 {
+					continue
+				}
+				s, err := d.str(id)
+				if err != nil {
+					return nil, err
+				}
+				rows[i].Values[ci] = s
+			case sz == 2:
+				if x := binary.LittleEndian.Uint16(v); x != 0 {
+					rows[i].Values[ci] = int32(int16(x ^ 0x8000))
+				}
+			default:
+				if x := binary.LittleEndian.Uint32(v); x != 0 {
+					rows[i].Values[ci] = int32(x ^ 0x80000000)
+				}
+			}
+		}
+		off += sz * n
+	}
+	// binary values name the stream holding their data: the table name and the row's keys, separated by dots
+	for ci, c := range t.Columns {
+		if !c.Binary() {
+			continue
+		}
+		for _, row := range rows {
+			if row.Values[ci] == nil {
+				continue
+			}
+			keys := []string{t.Name}
+			for ki, k := range t.Columns {
+				if k.Key {
+					keys = append(keys, format(row.Values[ki]))
+				}
+			}
+			row.Values[ci] = strings.Join(keys, ".")
+		}
+	}
+	return rows, nil
+}
+
+func format(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case int32:
+		return strconv.Itoa(int(v))
+	}
+	return ""
+}
+
+// Stream returns the stream holding the data of a binary column value
+func (d *Database) Stream(name string) (*mscfb.File, bool) {
+	f, ok := d.files[name]
+	return f, ok
+}
+
+// Property returns a value from the Property table, such as ProductName or ProductVersion
+func (d *Database) Property(name string) string {
+	rows, _ := d.Rows("Property")
+	for _, r := range rows {
+		if r.String("Property") == name {
+			return r.String("Value")
+		}
+	}
+	return ""
+}
@@ -0,0 +1,178 @@
+package msi
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/richardlehane/mscfb"
+	"github.com/richardlehane/mscfb/mscfbtest"
+)
+
+const alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz._"
+
+// encode compresses a stream name in the MSI manner
+func encode(name string, table bool) string {
+	var u []uint16
+	if table {
+		u = append(u, 0x4840)
+	}
+	for i := 0; i < len(name); {
+		a := strings.IndexByte(alphabet, name[i])
+		if a < 0 {
+			u = append(u, uint16(name[i]))
+			i++
+			continue
+		}
+		if i+1 < len(name) {
+			if b := strings.IndexByte(alphabet, name[i+1]); b >= 0 {
+				u = append(u, 0x3800+uint16(a)+uint16(b)<<6)
+				i += 2
+				continue
+			}
+		}
+		u = append(u, 0x4800+uint16(a))
+		i++
+	}
+	return string(utf16.Decode(u))
+}
+
+func TestDecodeName(t *testing.T) {
+	for _, n := range []string{"_StringPool", "Binary.Icon", "a", "File"} {
+		if got, table := DecodeName(encode(n, true)); got != n || !table {
+			t.Errorf("expecting %q, got %q %v", n, got, table)
+		}
+	}
+	if got, table := DecodeName("\x05SummaryInformation"); got != "\x05SummaryInformation" || table {
+		t.Errorf("bad decode of uncompressed name %q", got)
+	}
+}
+
+func app16(b []byte, v uint16) []byte { return append(b, byte(v), byte(v>>8)) }
+
+func app32(b []byte, v uint32) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+// db builds table streams. Columns are given as slices of values: strings are interned in the string pool.
+type db struct {
+	strs  []string
+	ids   map[string]int
+	files map[string][]byte
+}
+
+func (d *db) id(s string) uint16 {
+	if s == "" {
+		return 0
+	}
+	if i, ok := d.ids[s]; ok {
+		return uint16(i)
+	}
+	d.strs = append(d.strs, s)
+	d.ids[s] = len(d.strs)
+	return uint16(len(d.strs))
+}
+
+func (d *db) table(name string, cols ...[]interface{}) {
+	var b []byte
+	for _, col := range cols {
+		for _, v := range col {
+			switch v := v.(type) {
+			case string:
+				b = app16(b, d.id(v))
+			case int16:
+				b = app16(b, uint16(v)^0x8000)
+			case int32:
+				b = app32(b, uint32(v)^0x80000000)
+			case nil:
+				b = append(b, 0, 0)
+			}
+		}
+	}
+	d.files[name] = b
+}
+
+func TestDatabase(t *testing.T) {
+	d := &db{ids: make(map[string]int), files: make(map[string][]byte)}
+	long := strings.Repeat("long ", 14000)
+	const (
+		s72key = int16(0x2D48)
+		s0     = int16(0x1D00 | 0x0000)
+		i2key  = int16(0x2502)
+		i4     = int16(0x0104)
+		i2null = int16(0x1502)
+		bin    = int16(0x1900)
+	)
+	d.table("_Tables", []interface{}{"Property", "Media", "Binary"})
+	d.table("_Columns",
+		[]interface{}{"Property", "Property", "Media", "Media", "Media", "Binary", "Binary"},
+		[]interface{}{int16(1), int16(2), int16(1), int16(2), int16(3), int16(1), int16(2)},
+		[]interface{}{"Property", "Value", "DiskId", "LastSequence", "Volume", "Name", "Data"},
+		[]interface{}{s72key, s0, i2key, i4, i2null, s72key, bin},
+	)
+	d.table("Property", []interface{}{"ProductName", "ProductVersion", "Long"}, []interface{}{"Acme Widget", "1.2.3", long})
+	d.table("Media", []interface{}{int16(1), int16(2)}, []interface{}{int32(7), int32(-70000)}, []interface{}{nil, int16(-3)})
+	d.table("Binary", []interface{}{"Icon"}, []interface{}{int16(1)})
+	pool := app32(nil, 1252)
+	var data []byte
+	for _, s := range d.strs {
+		if len(s) > 0xFFFF {
+			pool = app16(app16(pool, 0), 3)
+			pool = app32(pool, uint32(len(s)))
+		} else {
+			pool = app16(app16(pool, uint16(len(s))), 1)
+		}
+		data = append(data, s...)
+	}
+	b := mscfbtest.New()
+	b.Stream(encode("_StringPool", true), pool)
+	b.Stream(encode("_StringData", true), data)
+	for n, v := range d.files {
+		b.Stream(encode(n, true), v)
+	}
+	b.Stream(encode("Binary.Icon", false), []byte("ICON"))
+	b.Stream("\x05SummaryInformation", make([]byte, 48))
+	buf, err := b.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := mscfb.New(bytes.NewReader(buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := New(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Property("ProductName") != "Acme Widget" || m.Property("ProductVersion") != "1.2.3" {
+		t.Errorf("bad properties %q %q", m.Property("ProductName"), m.Property("ProductVersion"))
+	}
+	if m.Property("Long") != long {
+		t.Errorf("bad long property, got %d bytes", len(m.Property("Long")))
+	}
+	if tables := m.Tables(); strings.Join(tables, ",") != "Binary,Media,Property,_Columns" {
+		t.Errorf("bad tables %v", tables)
+	}
+	rows, err := m.Rows("Media")
+	if err != nil || len(rows) != 2 {
+		t.Fatalf("bad Media rows %v %v", rows, err)
+	}
+	if rows[0].Int("DiskId") != 1 || rows[0].Int("LastSequence") != 7 || rows[0].Values[2] != nil {
+		t.Errorf("bad row %v", rows[0].Values)
+	}
+	if rows[1].Int("DiskId") != 2 || rows[1].Int("LastSequence") != -70000 || rows[1].Int("Volume") != -3 {
+		t.Errorf("bad row %v", rows[1].Values)
+	}
+	rows, err = m.Rows("Binary")
+	if err != nil || len(rows) != 1 {
+		t.Fatalf("bad Binary rows %v %v", rows, err)
+	}
+	f, ok := m.Stream(rows[0].String("Data"))
+	if !ok || f.Size != 4 {
+		t.Errorf("bad binary stream %q", rows[0].String("Data"))
+	}
+	if _, err := m.Rows("Component"); err != ErrNoTable {
+		t.Errorf("expecting ErrNoTable, got %v", err)
+	}
+}
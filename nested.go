@@ -0,0 +1,86 @@
+// Copyright 2013 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mscfb
+
+import (
+	"encoding/binary"
+	"strings"
+)
+
+// IsCompound reports whether this directory entry is a stream that begins with the compound file signature
+func (f *File) IsCompound() bool {
+	if f.objectType != stream || f.Size < int64(lenHeader) {
+		return false
+	}
+	buf := make([]byte, 8)
+	if n, _ := f.ReadAt(buf, 0); n < len(buf) {
+		return false
+	}
+	return binary.LittleEndian.Uint64(buf) == signature
+}
+
+// OpenCompound returns a Reader for a stream that is itself a compound file, such as an Excel workbook embedded in a Word document.
+// The returned Reader reads through its own copy of this File, so it doesn't disturb the seek position of this File.
+func (f *File) OpenCompound() (*Reader, error) {
+	if !f.IsCompound() {
+		return nil, Error{ErrFormat, "stream is not a compound file", f.Size}
+	}
+	c := *f
+	c.i, c.rem, c.curSector = 0, 0, f.startingSectorLoc
+	return New(&c)
+}
+
+// WalkFunc is called by Walk for each directory entry. The path is slash-separated, with the paths of nested compound files
+// separated from the path of their containing stream by "!/".
+// The error is non-nil if the entry is a stream with the compound file signature that couldn't be opened.
+// Returning a non-nil error stops the walk.
+type WalkFunc func(path string, f *File, err error) error
+
+// maxNesting is the depth of nested compound files at which Walk stops opening them
+const maxNesting = 8
+
+// Walk calls fn for every directory entry, other than root entries, in the compound file and, recursively, in any streams that are
+// themselves compound files. Paths are prefixed with name, e.g. Walk("outer.doc", fn) yields paths like outer.doc!/ObjectPool/_123/Workbook.
+// Compound files nested more than 8 deep aren't opened: fn is called for them with an error.
+func (r *Reader) Walk(name string, fn WalkFunc) error {
+	return r.walk(name, fn, 0)
+}
+
+func (r *Reader) walk(name string, fn WalkFunc, depth int) error {
+	for _, f := range r.File[1:] {
+		path := name + "!/" + strings.Join(append(append([]string{}, f.Path...), f.Name), "/")
+		if !f.IsCompound() {
+			if err := fn(path, f, nil); err != nil {
+				return err
+			}
+			continue
+		}
+		var nested *Reader
+		var err error = Error{ErrTraverse, "compound files nested too deeply", int64(depth)}
+		if depth < maxNesting {
+			nested, err = f.OpenCompound()
+		}
+		if err := fn(path, f, err); err != nil {
+			return err
+		}
+		if err != nil {
+			continue
+		}
+		if err := nested.walk(path, fn, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package mscfb
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/richardlehane/mscfb/mscfbtest"
+)
+
+func TestNested(t *testing.T) {
+	workbook := bytes.Repeat([]byte("cells"), 1000)
+	xls := mscfbtest.New()
+	xls.Stream("Workbook", workbook)
+	inner, err := xls.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	outer := mscfbtest.New()
+	outer.Stream("WordDocument", make([]byte, 100))
+	outer.Storage("ObjectPool").Storage("_123").Stream("Package", inner)
+	outer.Stream("Broken", append(append([]byte{}, inner[:8]...), make([]byte, 600)...))
+	doc, err := outer.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := New(bytes.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	var broken error
+	err = r.Walk("outer.doc", func(path string, f *File, err error) error {
+		paths = append(paths, path)
+		if err != nil {
+			broken = err
+			return nil
+		}
+		if f.Name == "Workbook" {
+			buf, err := io.ReadAll(f)
+			if err != nil || !bytes.Equal(buf, workbook) {
+				t.Errorf("bad nested read: %d bytes %v", len(buf), err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []string{
+		"outer.doc!/Broken",
+		"outer.doc!/ObjectPool",
+		"outer.doc!/ObjectPool/_123",
+		"outer.doc!/ObjectPool/_123/Package",
+		"outer.doc!/ObjectPool/_123/Package!/Workbook",
+		"outer.doc!/WordDocument",
+	}
+	if !reflect.DeepEqual(paths, expect) {
+		t.Errorf("expecting %v, got %v", expect, paths)
+	}
+	if broken == nil {
+		t.Error("expecting an error for the broken nested compound file")
+	}
+	for _, f := range r.File {
+		if f.Name == "WordDocument" {
+			if _, err := f.OpenCompound(); err == nil {
+				t.Error("expecting an error opening a stream that isn't a compound file")
+			}
+		}
+	}
+}
+
+func TestNestedDepth(t *testing.T) {
+	b := mscfbtest.New()
+	b.Stream("Stream", []byte("innermost"))
+	doc, err := b.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < maxNesting+4; i++ {
+		b = mscfbtest.New()
+		b.Stream("Nested", doc)
+		if doc, err = b.Bytes(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r, err := New(bytes.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries int
+	var deep error
+	if err := r.Walk("doc", func(path string, f *File, err error) error {
+		entries++
+		if err != nil {
+			deep = err
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if entries != maxNesting+1 || deep == nil {
+		t.Errorf("expecting the walk to stop at %d nested files, got %d entries and error %v", maxNesting, entries, deep)
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright 2013 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mscfb
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Reason is the purpose of a read or write of the underlying reader
+type Reason int
+
+const (
+	ReasonHeader     Reason = iota // the compound file header
+	ReasonDIFAT                    // DIFAT sectors
+	ReasonFAT                      // FAT lookups of the next sector in a chain
+	ReasonMiniFAT                  // mini FAT lookups of the next mini sector in a chain
+	ReasonDirectory                // directory sectors
+	ReasonMiniStream               // data of streams held in the mini stream
+	ReasonStream                   // data of streams held in regular sectors
+	numReasons
+)
+
+func (r Reason) String() string {
+	switch r {
+	case ReasonHeader:
+		return "header"
+	case ReasonDIFAT:
+		return "DIFAT"
+	case ReasonFAT:
+		return "FAT"
+	case ReasonMiniFAT:
+		return "mini FAT"
+	case ReasonDirectory:
+		return "directory"
+	case ReasonMiniStream:
+		return "mini stream"
+	case ReasonStream:
+		return "stream"
+	}
+	return "unknown"
+}
+
+// Op is a read or write
+type Op int
+
+const (
+	OpRead Op = iota
+	OpWrite
+)
+
+// Event describes a call to the ReadAt, WriteAt or Slice method of the underlying reader
+type Event struct {
+	Op       Op
+	Reason   Reason
+	Offset   int64
+	Length   int // bytes requested
+	N        int // bytes read or written
+	Err      error
+	Duration time.Duration
+}
+
+// Observer receives an Event for each read and write of the underlying reader by a Reader and its Files. Observe is
+// called synchronously, after the read or write returns.
+type Observer interface {
+	Observe(e Event)
+}
+
+// Counter holds aggregate counts of reads and writes
+type Counter struct {
+	Reads        int64
+	Writes       int64
+	BytesRead    int64
+	BytesWritten int64
+	Errors       int64
+}
+
+type counters [numReasons]Counter
+
+func (r *Reader) record(op Op, reason Reason, off int64, length, n int, err error, start time.Time) {
+	c := &r.counters[reason]
+	if op == OpRead {
+		atomic.AddInt64(&c.Reads, 1)
+		atomic.AddInt64(&c.BytesRead, int64(n))
+	} else {
+		atomic.AddInt64(&c.Writes, 1)
+		atomic.AddInt64(&c.BytesWritten, int64(n))
+	}
+	if err != nil {
+		atomic.AddInt64(&c.Errors, 1)
+	}
+	if r.observer != nil {
+		r.observer.Observe(Event{op, reason, off, length, n, err, time.Since(start)})
+	}
+}
+
+// Stats returns aggregate counts of the reads and writes of the underlying reader, by reason
+func (r *Reader) Stats() map[Reason]Counter {
+	ret := make(map[Reason]Counter, numReasons)
+	for i := range r.counters {
+		c := &r.counters[i]
+		ret[Reason(i)] = Counter{
+			Reads:        atomic.LoadInt64(&c.Reads),
+			Writes:       atomic.LoadInt64(&c.Writes),
+			BytesRead:    atomic.LoadInt64(&c.BytesRead),
+			BytesWritten: atomic.LoadInt64(&c.BytesWritten),
+			Errors:       atomic.LoadInt64(&c.Errors),
+		}
+	}
+	return ret
+}
+
+func (r *Reader) now() time.Time {
+	if r.observer == nil {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+// read reads from the underlying reader
+func (r *Reader) read(reason Reason, p []byte, off int64) (int, error) {
+	start := r.now()
+	n, err := r.ra.ReadAt(p, off)
+	r.record(OpRead, reason, off, len(p), n, err, start)
+	return n, err
+}
+
+// slice slices the underlying Slicer
+func (r *Reader) slice(reason Reason, off int64, length int) ([]byte, error) {
+	start := r.now()
+	b, err := r.ra.(Slicer).Slice(off, length)
+	r.record(OpRead, reason, off, length, len(b), err, start)
+	return b, err
+}
+
+// write writes to the underlying writer
+func (r *Reader) write(reason Reason, p []byte, off int64) (int, error) {
+	start := r.now()
+	n, err := r.wa.WriteAt(p, off)
+	r.record(OpWrite, reason, off, len(p), n, err, start)
+	return n, err
+}
+
+// reason returns the reason for reads and writes of this stream's data
+func (f *File) reason() Reason {
+	if f.Size < miniStreamCutoffSize {
+		return ReasonMiniStream
+	}
+	return ReasonStream
+}
@@ -0,0 +1,66 @@
+package mscfb
+
+import (
+	"io"
+	"os"
+	"testing"
+)
+
+type recorder []Event
+
+func (r *recorder) Observe(e Event) { *r = append(*r, e) }
+
+// rw is a writable in-memory compound file
+type rw []byte
+
+func (b rw) ReadAt(p []byte, off int64) (int, error) {
+	return (&mapping{b: b}).ReadAt(p, off)
+}
+
+func (b rw) WriteAt(p []byte, off int64) (int, error) {
+	return copy(b[off:], p), nil
+}
+
+func TestObserver(t *testing.T) {
+	raw, _ := os.ReadFile(testDoc)
+	rec := &recorder{}
+	doc, err := NewWithOptions(rw(raw), Options{Observer: rec})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := (*rec)[0]; e.Reason != ReasonHeader || e.Op != OpRead || e.Offset != 0 || e.Length != lenHeader || e.N != lenHeader {
+		t.Errorf("expecting a header read first, got %+v", e)
+	}
+	for _, f := range doc.File {
+		if _, err := io.ReadAll(f); err != nil {
+			t.Fatal(err)
+		}
+		if f.Name == "WordDocument" {
+			f.WriteAt([]byte("x"), 0)
+		}
+	}
+	total := make(map[Reason]Counter)
+	for _, e := range *rec {
+		c := total[e.Reason]
+		if e.Op == OpRead {
+			c.Reads++
+			c.BytesRead += int64(e.N)
+		} else {
+			c.Writes++
+			c.BytesWritten += int64(e.N)
+		}
+		total[e.Reason] = c
+	}
+	stats := doc.Stats()
+	for _, reason := range []Reason{ReasonHeader, ReasonFAT, ReasonMiniFAT, ReasonDirectory, ReasonMiniStream, ReasonStream} {
+		if stats[reason].Reads == 0 {
+			t.Errorf("expecting %s reads", reason)
+		}
+		if stats[reason] != total[reason] {
+			t.Errorf("%s: stats %+v don't match events %+v", reason, stats[reason], total[reason])
+		}
+	}
+	if stats[ReasonStream].Writes != 1 || stats[ReasonStream].BytesWritten != 1 {
+		t.Errorf("expecting a stream write, got %+v", stats[ReasonStream])
+	}
+}
@@ -0,0 +1,355 @@
+// Copyright 2013 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package offcrypto
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"hash"
+	"io"
+	"sync"
+
+	"github.com/richardlehane/mscfb"
+	"github.com/richardlehane/mscfb/internal/ole"
+)
+
+const (
+	algAES128 uint32 = 0x660E
+	algAES192 uint32 = 0x660F
+	algAES256 uint32 = 0x6610
+)
+
+const segmentSize = 4096
+
+// ECMA-376: encrypted OOXML packages keep an EncryptionInfo stream alongside the EncryptedPackage stream
+func detectECMA(f *mscfb.File) (*detection, error) {
+	info, err := ole.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	if len(info) < 8 {
+		return nil, ErrFormat
+	}
+	major, minor := binary.LittleEndian.Uint16(info), binary.LittleEndian.Uint16(info[2:])
+	switch {
+	case major == 4 && minor == 4:
+		return &detection{scheme: Agile, info: info}, nil
+	case major >= 2 && major <= 4 && minor == 2:
+		return &detection{scheme: Standard, info: info}, nil
+	}
+	return nil, ErrUnsupported
+}
+
+// standardKey verifies the password against a Standard encryption header and returns an AES-ECB package decrypter (MS-OFFCRYPTO 2.3.4.7)
+func standardKey(info []byte, password string) (func(int64, []byte), error) {
+	h, err := parseHeader(info[4:])
+	if err != nil {
+		return nil, err
+	}
+	if h.algID != algAES128 && h.algID != algAES192 && h.algID != algAES256 {
+		return nil, ErrUnsupported
+	}
+	ks := int(h.keySize / 8)
+	if ks != 16 && ks != 24 && ks != 32 {
+		return nil, ErrFormat
+	}
+	block, err := aes.NewCipher(standardDerive(h.salt, ole.EncodeUTF16(password), ks))
+	if err != nil {
+		return nil, err
+	}
+	if len(h.verifierHash) < 32 {
+		return nil, ErrFormat
+	}
+	verifier := make([]byte, 16)
+	ecb(block, verifier, h.verifier)
+	vh := make([]byte, 32)
+	ecb(block, vh, h.verifierHash[:32])
+	if !bytes.Equal(digest(sha1.New, verifier), vh[:sha1.Size]) {
+		return nil, ErrPassword
+	}
+	return func(_ int64, seg []byte) { ecb(block, seg, seg) }, nil
+}
+
+func standardDerive(salt, pw []byte, ks int) []byte {
+	hn := iterate(sha1.New, salt, pw, 50000)
+	hfinal := digest(sha1.New, hn, []byte{0, 0, 0, 0})
+	x := make([]byte, 0, 40)
+	for _, c := range []byte{0x36, 0x5C} {
+		buf := bytes.Repeat([]byte{c}, 64)
+		for i, v := range hfinal {
+			buf[i] ^= v
+		}
+		x = append(x, digest(sha1.New, buf)...)
+	}
+	return x[:ks]
+}
+
+func ecb(b cipher.Block, dst, src []byte) {
+	for i := 0; i+aes.BlockSize <= len(src); i += aes.BlockSize {
+		b.Decrypt(dst[i:i+aes.BlockSize], src[i:i+aes.BlockSize])
+	}
+}
+
+// agile encryption descriptor (MS-OFFCRYPTO 2.3.4.10)
+type agileInfo struct {
+	KeyData       agileParams `xml:"keyData"`
+	KeyEncryptors []struct {
+		URI          string      `xml:"uri,attr"`
+		EncryptedKey agileParams `xml:"encryptedKey"`
+	} `xml:"keyEncryptors>keyEncryptor"`
+}
+
+type agileParams struct {
+	SpinCount                  int    `xml:"spinCount,attr"`
+	SaltSize                   int    `xml:"saltSize,attr"`
+	BlockSize                  int    `xml:"blockSize,attr"`
+	KeyBits                    int    `xml:"keyBits,attr"`
+	HashSize                   int    `xml:"hashSize,attr"`
+	CipherAlgorithm            string `xml:"cipherAlgorithm,attr"`
+	CipherChaining             string `xml:"cipherChaining,attr"`
+	HashAlgorithm              string `xml:"hashAlgorithm,attr"`
+	SaltValue                  string `xml:"saltValue,attr"`
+	EncryptedVerifierHashInput string `xml:"encryptedVerifierHashInput,attr"`
+	EncryptedVerifierHashValue string `xml:"encryptedVerifierHashValue,attr"`
+	EncryptedKeyValue          string `xml:"encryptedKeyValue,attr"`
+}
+
+const passwordEncryptor = "http://schemas.microsoft.com/office/2006/keyEncryptor/password"
+
+var (
+	blockVerifierInput = []byte{0xfe, 0xa7, 0xd2, 0x76, 0x3b, 0x4b, 0x9e, 0x79}
+	blockVerifierValue = []byte{0xd7, 0xaa, 0x0f, 0x6d, 0x30, 0x61, 0x34, 0x4e}
+	blockKeyValue      = []byte{0x14, 0x6e, 0x0b, 0xe7, 0xab, 0xac, 0xd0, 0xd6}
+)
+
+func hashFunc(name string) func() hash.Hash {
+	switch name {
+	case "SHA1", "SHA-1":
+		return sha1.New
+	case "SHA256":
+		return sha256.New
+	case "SHA384":
+		return sha512.New384
+	case "SHA512":
+		return sha512.New
+	case "MD5":
+		return md5.New
+	}
+	return nil
+}
+
+// agileKey verifies the password against an Agile encryption descriptor and returns an AES-CBC package decrypter
+func agileKey(info []byte, password string) (func(int64, []byte), error) {
+	ai := &agileInfo{}
+	if err := xml.Unmarshal(info[8:], ai); err != nil {
+		return nil, ErrFormat
+	}
+	var ek *agileParams
+	for i := range ai.KeyEncryptors {
+		if ai.KeyEncryptors[i].URI == passwordEncryptor {
+			ek = &ai.KeyEncryptors[i].EncryptedKey
+		}
+	}
+	if ek == nil {
+		return nil, ErrUnsupported
+	}
+	kd := &ai.KeyData
+	for _, p := range []*agileParams{kd, ek} {
+		if p.CipherAlgorithm != "AES" || p.CipherChaining != "ChainingModeCBC" || hashFunc(p.HashAlgorithm) == nil {
+			return nil, ErrUnsupported
+		}
+		if p.BlockSize != aes.BlockSize || (p.KeyBits != 128 && p.KeyBits != 192 && p.KeyBits != 256) {
+			return nil, ErrFormat
+		}
+	}
+	dec := func(s string) []byte {
+		b, _ := base64.StdEncoding.DecodeString(s)
+		return b
+	}
+	salt, kdSalt := dec(ek.SaltValue), dec(kd.SaltValue)
+	input, value, keyValue := dec(ek.EncryptedVerifierHashInput), dec(ek.EncryptedVerifierHashValue), dec(ek.EncryptedKeyValue)
+	if len(salt) == 0 || len(kdSalt) == 0 || ek.SpinCount < 0 || ek.SpinCount > 10000000 {
+		return nil, ErrFormat
+	}
+	hf := hashFunc(ek.HashAlgorithm)
+	hn := iterate(hf, salt, ole.EncodeUTF16(password), ek.SpinCount)
+	cbc := func(blockKey, b []byte) ([]byte, error) {
+		key := fit(digest(hf, hn, blockKey), ek.KeyBits/8)
+		return decryptCBC(key, fit(salt, aes.BlockSize), b)
+	}
+	vi, err := cbc(blockVerifierInput, input)
+	if err != nil {
+		return nil, err
+	}
+	vv, err := cbc(blockVerifierValue, value)
+	if err != nil {
+		return nil, err
+	}
+	if ek.SaltSize > len(vi) {
+		return nil, ErrFormat
+	}
+	vh := digest(hf, vi[:ek.SaltSize])
+	if len(vv) < len(vh) || !bytes.Equal(vh, vv[:len(vh)]) {
+		return nil, ErrPassword
+	}
+	secret, err := cbc(blockKeyValue, keyValue)
+	if err != nil {
+		return nil, err
+	}
+	if len(secret) < kd.KeyBits/8 {
+		return nil, ErrFormat
+	}
+	block, err := aes.NewCipher(secret[:kd.KeyBits/8])
+	if err != nil {
+		return nil, err
+	}
+	kdh := hashFunc(kd.HashAlgorithm)
+	return func(i int64, seg []byte) {
+		idx := make([]byte, 4)
+		binary.LittleEndian.PutUint32(idx, uint32(i))
+		iv := fit(digest(kdh, kdSalt, idx), aes.BlockSize)
+		seg = seg[:len(seg)/aes.BlockSize*aes.BlockSize]
+		cipher.NewCBCDecrypter(block, iv).CryptBlocks(seg, seg)
+	}, nil
+}
+
+func decryptCBC(key, iv, b []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 || len(b)%aes.BlockSize != 0 {
+		return nil, ErrFormat
+	}
+	out := make([]byte, len(b))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(out, b)
+	return out, nil
+}
+
+// iterate hashes the salt and password, then rehashes with an iterator spin times
+func iterate(hf func() hash.Hash, salt, pw []byte, spin int) []byte {
+	h := digest(hf, salt, pw)
+	idx := make([]byte, 4)
+	for i := 0; i < spin; i++ {
+		binary.LittleEndian.PutUint32(idx, uint32(i))
+		h = digest(hf, idx, h)
+	}
+	return h
+}
+
+func digest(hf func() hash.Hash, parts ...[]byte) []byte {
+	h := hf()
+	for _, p := range parts {
+		h.Write(p)
+	}
+	return h.Sum(nil)
+}
+
+// fit truncates b to n bytes or pads it with 0x36
+func fit(b []byte, n int) []byte {
+	if len(b) >= n {
+		return b[:n]
+	}
+	return append(append([]byte{}, b...), bytes.Repeat([]byte{0x36}, n-len(b))...)
+}
+
+// Package returns the decrypted OOXML package (a ZIP file) of an ECMA-376 encrypted document
+func (d *Decrypter) Package() (*io.SectionReader, error) {
+	if d.pkg == nil {
+		return nil, ErrUnsupported
+	}
+	f := find(d.r, "EncryptedPackage")
+	if f == nil {
+		return nil, ErrFormat
+	}
+	sz := make([]byte, 8)
+	if err := readAt(f, sz, 0); err != nil {
+		return nil, err
+	}
+	size := int64(binary.LittleEndian.Uint64(sz))
+	if size < 0 || size > f.Size-8 {
+		return nil, ErrFormat
+	}
+	return io.NewSectionReader(&pkgReader{f: f, size: size, decrypt: d.pkg, idx: -1}, 0, size), nil
+}
+
+// pkgReader decrypts an EncryptedPackage stream one segment at a time
+type pkgReader struct {
+	f       *mscfb.File
+	size    int64
+	decrypt func(int64, []byte)
+	mu      sync.Mutex
+	idx     int64 // index of the cached segment
+	seg     []byte
+}
+
+func (pr *pkgReader) segment(i int64) ([]byte, error) {
+	if i == pr.idx {
+		return pr.seg, nil
+	}
+	off := 8 + i*segmentSize
+	l := pr.f.Size - off
+	if l > segmentSize {
+		l = segmentSize
+	}
+	if l <= 0 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if pr.seg == nil {
+		pr.seg = make([]byte, segmentSize)
+	}
+	pr.idx = -1
+	seg := pr.seg[:l]
+	if err := readAt(pr.f, seg, off); err != nil {
+		return nil, err
+	}
+	pr.decrypt(i, seg)
+	pr.idx = i
+	return seg, nil
+}
+
+func (pr *pkgReader) ReadAt(p []byte, off int64) (int, error) {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+	var n int
+	for n < len(p) {
+		pos := off + int64(n)
+		if pos >= pr.size {
+			return n, io.EOF
+		}
+		seg, err := pr.segment(pos / segmentSize)
+		if err != nil {
+			return n, err
+		}
+		rel := pos % segmentSize
+		if rel >= int64(len(seg)) {
+			return n, io.ErrUnexpectedEOF
+		}
+		c := copy(p[n:], seg[rel:])
+		if rem := pr.size - pos; int64(c) > rem {
+			c = int(rem)
+		}
+		n += c
+	}
+	return n, nil
+}
@@ -0,0 +1,160 @@
+package offcrypto
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"github.com/richardlehane/mscfb/mscfbtest"
+)
+
+func testZip(t *testing.T) []byte {
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	w, _ := zw.Create("word/document.xml")
+	w.Write(text(10000))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func padTo(b []byte, n int) []byte {
+	if len(b)%n == 0 {
+		return b
+	}
+	return append(b, make([]byte, n-len(b)%n)...)
+}
+
+// encryptPackage lays out an EncryptedPackage stream, encrypting each segment with enc
+func encryptPackage(pkg []byte, enc func(int, []byte)) []byte {
+	out := make([]byte, 8)
+	binary.LittleEndian.PutUint64(out, uint64(len(pkg)))
+	for i := 0; i*segmentSize < len(pkg); i++ {
+		e := (i + 1) * segmentSize
+		if e > len(pkg) {
+			e = len(pkg)
+		}
+		seg := padTo(append([]byte{}, pkg[i*segmentSize:e]...), aes.BlockSize)
+		enc(i, seg)
+		out = append(out, seg...)
+	}
+	return out
+}
+
+func checkPackage(t *testing.T, b *mscfbtest.Builder, scheme Scheme, pkg []byte) {
+	r := open(t, b)
+	if s, err := Detect(r); s != scheme || err != nil {
+		t.Fatalf("expecting %s, got %s %v", scheme, s, err)
+	}
+	if _, err := New(r, "wrong"); err != ErrPassword {
+		t.Fatalf("expecting a password error, got %v", err)
+	}
+	d, err := New(r, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sr, err := d.Package()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := io.ReadAll(sr)
+	if !bytes.Equal(got, pkg) {
+		t.Fatal("bad package decryption")
+	}
+	zr, err := zip.NewReader(sr, sr.Size())
+	if err != nil || len(zr.File) != 1 {
+		t.Fatalf("expecting a readable zip, got %v", err)
+	}
+}
+
+// standardInfo lays out a Standard encryption header for password and salt, AES-128 key 9dc056d0a304b7bb593e506867015d2a
+// (derived by an independent implementation of MS-OFFCRYPTO)
+func standardInfo(major uint16) []byte {
+	info := make([]byte, 12+32)
+	binary.LittleEndian.PutUint16(info, major)
+	binary.LittleEndian.PutUint16(info[2:], 2)
+	binary.LittleEndian.PutUint32(info[4:], 0x24)
+	binary.LittleEndian.PutUint32(info[8:], 32)
+	binary.LittleEndian.PutUint32(info[12:], 0x24)
+	binary.LittleEndian.PutUint32(info[20:], algAES128)
+	binary.LittleEndian.PutUint32(info[24:], algSHA1)
+	binary.LittleEndian.PutUint32(info[28:], 128)
+	info = append(info, 16, 0, 0, 0)
+	info = append(info, salt...)
+	info = append(info, unhex("8c478cd51a9e08827277a8c5c52682ae")...)
+	info = append(info, 20, 0, 0, 0)
+	return append(info, unhex("9f5c4f7807ccfdd5bb652928535926462ad51fbf0b18e3cfd4bf02441bb32016")...)
+}
+
+func TestStandard(t *testing.T) {
+	dec, err := standardKey(standardInfo(4), password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seg := unhex("04a4a96efc7e9ca248fb907250c8fa7fb111347aa29c8949370a83019341ab962a0d810cccf6fdca706ccbae44753889e92fdec96703fa61c00618836e8566a9")
+	if dec(0, seg); !bytes.Equal(seg, text(64)) {
+		t.Fatal("bad segment decryption")
+	}
+	pkg := testZip(t)
+	block, _ := aes.NewCipher(unhex("9dc056d0a304b7bb593e506867015d2a"))
+	for _, major := range []uint16{2, 3, 4} {
+		b := mscfbtest.New()
+		b.Stream("EncryptionInfo", standardInfo(major))
+		b.Stream("EncryptedPackage", encryptPackage(pkg, func(_ int, seg []byte) {
+			for i := 0; i < len(seg); i += aes.BlockSize {
+				block.Encrypt(seg[i:i+aes.BlockSize], seg[i:i+aes.BlockSize])
+			}
+		}))
+		checkPackage(t, b, Standard, pkg)
+	}
+}
+
+// the encrypted values in the agile descriptor and segments were produced by an independent implementation of MS-OFFCRYPTO
+// for password and salt, a spin count of 1000, the key data salt "fedcba9876543210" and the secret below
+func TestAgile(t *testing.T) {
+	kdSalt := []byte("fedcba9876543210")
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	params := `saltSize="16" blockSize="16" keyBits="256" hashSize="64" cipherAlgorithm="AES" cipherChaining="ChainingModeCBC" hashAlgorithm="SHA512"`
+	xml := `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+		`<encryption xmlns="http://schemas.microsoft.com/office/2006/encryption" xmlns:p="http://schemas.microsoft.com/office/2006/keyEncryptor/password">` +
+		`<keyData ` + params + ` saltValue="` + base64.StdEncoding.EncodeToString(kdSalt) + `"/>` +
+		`<keyEncryptors><keyEncryptor uri="http://schemas.microsoft.com/office/2006/keyEncryptor/password">` +
+		`<p:encryptedKey spinCount="1000" ` + params + ` saltValue="` + base64.StdEncoding.EncodeToString(salt) +
+		`" encryptedVerifierHashInput="Z624QRxVf/leF+dhCXztQA==` +
+		`" encryptedVerifierHashValue="duHuBxRFZAtKrSIV4pAGB1VPUc50tpcZrWcqoJrlpS8IyvBTAWXrKOSDx57cTRaLl9H0Lyx14BktLMfeuQTZYg==` +
+		`" encryptedKeyValue="8McjwfL8/VvIls5krUV8m6m3Ogr1mqq/pdfelSfUVY8="/>` +
+		`</keyEncryptor></keyEncryptors></encryption>`
+	info := append([]byte{4, 0, 4, 0, 0x40, 0, 0, 0}, xml...)
+	dec, err := agileKey(info, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, h := range []string{
+		"7b4f97ff5eb71fe7700340d15b1315803aeba126437f65cd5ea5e55f6d7cf7612e6dd02233969cc177ab57eb65ba7e90eb076b2ff771ae1e749f75a1451d71db",
+		"ede1a3aaf8c6adeb73677676f5b87e754e4d99f6a0d626109b7100f8022e3fa419260482d13bf60ce9025b25de50eb719f0feff04731dd8d98890c3a85a6b586",
+	} {
+		seg := unhex(h)
+		if dec(int64(i), seg); !bytes.Equal(seg, text(64)) {
+			t.Fatalf("bad decryption of segment %d", i)
+		}
+	}
+	pkg := testZip(t)
+	block, _ := aes.NewCipher(secret)
+	enc := encryptPackage(pkg, func(i int, seg []byte) {
+		idx := make([]byte, 4)
+		binary.LittleEndian.PutUint32(idx, uint32(i))
+		iv := sha512.Sum512(append(append([]byte{}, kdSalt...), idx...))
+		cipher.NewCBCEncrypter(block, iv[:aes.BlockSize]).CryptBlocks(seg, seg)
+	})
+	b := mscfbtest.New()
+	b.Stream("EncryptionInfo", info)
+	b.Stream("EncryptedPackage", enc)
+	checkPackage(t, b, Agile, pkg)
+}
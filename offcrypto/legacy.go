@@ -0,0 +1,234 @@
+// Copyright 2013 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package offcrypto
+
+import (
+	"bytes"
+	"crypto/rc4"
+	"encoding/binary"
+	"io"
+
+	"github.com/richardlehane/mscfb"
+	"github.com/richardlehane/mscfb/internal/ole"
+)
+
+// scheme distinguishes RC4 from RC4 CryptoAPI by the EncryptionVersionInfo
+func scheme(info []byte) Scheme {
+	if len(info) >= 4 && binary.LittleEndian.Uint16(info) == 1 && binary.LittleEndian.Uint16(info[2:]) == 1 {
+		return RC4
+	}
+	return RC4CryptoAPI
+}
+
+// Word: the FibBase flags record encryption and the encryption header starts the table stream
+func detectWord(r *mscfb.Reader, f *mscfb.File) (*detection, error) {
+	fib := make([]byte, 18)
+	if err := readAt(f, fib, 0); err != nil {
+		return nil, err
+	}
+	if binary.LittleEndian.Uint16(fib) != 0xA5EC {
+		return nil, ErrFormat
+	}
+	flags := binary.LittleEndian.Uint16(fib[10:])
+	if flags&0x0100 == 0 { // fEncrypted
+		return &detection{scheme: None, app: word}, nil
+	}
+	if flags&0x8000 != 0 { // fObfuscated
+		return &detection{scheme: XOR, app: word}, nil
+	}
+	det := &detection{app: word, table: "0Table", lKey: int64(binary.LittleEndian.Uint32(fib[14:]))}
+	if flags&0x0200 != 0 { // fWhichTblStm
+		det.table = "1Table"
+	}
+	t := find(r, det.table)
+	if t == nil {
+		return nil, ErrFormat
+	}
+	if det.lKey > t.Size {
+		return nil, ErrFormat
+	}
+	det.info = make([]byte, det.lKey)
+	if err := readAt(t, det.info, 0); err != nil {
+		return nil, err
+	}
+	det.scheme = scheme(det.info)
+	return det, nil
+}
+
+// BIFF record types
+const (
+	recBOF          uint16 = 0x0809
+	recEOF          uint16 = 0x000A
+	recFilePass     uint16 = 0x002F
+	recBoundSheet   uint16 = 0x0085
+	recUsrExcl      uint16 = 0x0194
+	recFileLock     uint16 = 0x0195
+	recInterfaceHdr uint16 = 0x00E1
+	recRRDInfo      uint16 = 0x0196
+	recRRDHead      uint16 = 0x0138
+)
+
+// Excel: a FILEPASS record follows the first BOF record in the workbook stream
+func detectExcel(f *mscfb.File) (*detection, error) {
+	hdr := make([]byte, 4)
+	for pos := int64(0); pos+4 <= f.Size; {
+		if err := readAt(f, hdr, pos); err != nil {
+			return nil, err
+		}
+		typ, l := binary.LittleEndian.Uint16(hdr), int64(binary.LittleEndian.Uint16(hdr[2:]))
+		switch typ {
+		case recFilePass:
+			data := make([]byte, l)
+			if err := readAt(f, data, pos+4); err != nil {
+				return nil, err
+			}
+			if len(data) < 2 {
+				return nil, ErrFormat
+			}
+			if binary.LittleEndian.Uint16(data) == 0 {
+				return &detection{scheme: XOR, app: excel}, nil
+			}
+			return &detection{scheme: scheme(data[2:]), app: excel, info: data[2:]}, nil
+		case recEOF, recBoundSheet:
+			return &detection{scheme: None, app: excel}, nil
+		}
+		pos += 4 + l
+	}
+	return &detection{scheme: None, app: excel}, nil
+}
+
+// excelPlain lists the parts of the workbook stream that aren't encrypted: record headers, a handful of records,
+// and the stream position in BOUNDSHEET records
+func excelPlain(f *mscfb.File) ([][2]int64, error) {
+	var plain [][2]int64
+	hdr := make([]byte, 4)
+	for pos := int64(0); pos+4 <= f.Size; {
+		if err := readAt(f, hdr, pos); err != nil {
+			return nil, err
+		}
+		typ, l := binary.LittleEndian.Uint16(hdr), int64(binary.LittleEndian.Uint16(hdr[2:]))
+		plain = append(plain, [2]int64{pos, 4})
+		switch typ {
+		case recBOF, recFilePass, recUsrExcl, recFileLock, recInterfaceHdr, recRRDInfo, recRRDHead:
+			plain = append(plain, [2]int64{pos + 4, l})
+		case recBoundSheet:
+			plain = append(plain, [2]int64{pos + 4, 4})
+		}
+		pos += 4 + l
+	}
+	return plain, nil
+}
+
+// PowerPoint record types
+const (
+	rtUserEditAtom            uint16 = 0x0FF5
+	rtPersistDirectoryAtom    uint16 = 0x1772
+	rtCryptSession10Container uint16 = 0x2F14
+)
+
+const encryptedToken uint32 = 0xF3D1C4DF
+
+// persist maps persist object IDs to their offsets in the PowerPoint Document stream
+type persist struct {
+	dir     map[uint32]uint32
+	session uint32 // persist ID of the CryptSession10Container
+}
+
+// PowerPoint: the Current User stream flags encryption and the last UserEditAtom references a CryptSession10Container
+func detectPowerPoint(r *mscfb.Reader, f *mscfb.File) (*detection, error) {
+	cu := make([]byte, 20)
+	if err := readAt(f, cu, 0); err != nil {
+		return nil, err
+	}
+	if binary.LittleEndian.Uint32(cu[12:]) != encryptedToken {
+		return &detection{scheme: None, app: powerpoint}, nil
+	}
+	doc := find(r, "PowerPoint Document")
+	if doc == nil {
+		return nil, ErrFormat
+	}
+	buf, err := ole.ReadAll(doc)
+	if err != nil {
+		return nil, err
+	}
+	p := &persist{dir: make(map[uint32]uint32)}
+	seen := make(map[uint32]bool)
+	for off, first := binary.LittleEndian.Uint32(cu[16:]), true; ; first = false {
+		if seen[off] || int64(off)+36 > int64(len(buf)) || binary.LittleEndian.Uint16(buf[off+2:]) != rtUserEditAtom {
+			return nil, ErrFormat
+		}
+		seen[off] = true
+		ue := buf[off:]
+		if first {
+			if binary.LittleEndian.Uint32(ue[4:]) < 0x20 || int64(off)+40 > int64(len(buf)) {
+				return nil, ErrFormat
+			}
+			p.session = binary.LittleEndian.Uint32(ue[36:])
+		}
+		pd := int64(binary.LittleEndian.Uint32(ue[20:]))
+		if pd+8 > int64(len(buf)) || binary.LittleEndian.Uint16(buf[pd+2:]) != rtPersistDirectoryAtom {
+			return nil, ErrFormat
+		}
+		end := pd + 8 + int64(binary.LittleEndian.Uint32(buf[pd+4:]))
+		if end > int64(len(buf)) {
+			return nil, ErrFormat
+		}
+		for i := pd + 8; i+4 <= end; {
+			id, n := binary.LittleEndian.Uint32(buf[i:])&0xFFFFF, binary.LittleEndian.Uint32(buf[i:])>>20
+			i += 4
+			for j := uint32(0); j < n && i+4 <= end; j++ {
+				// the newest edit wins
+				if _, ok := p.dir[id+j]; !ok {
+					p.dir[id+j] = binary.LittleEndian.Uint32(buf[i:])
+				}
+				i += 4
+			}
+		}
+		off = binary.LittleEndian.Uint32(ue[16:])
+		if off == 0 {
+			break
+		}
+	}
+	so, ok := p.dir[p.session]
+	if !ok || int64(so)+8 > int64(len(buf)) || binary.LittleEndian.Uint16(buf[so+2:]) != rtCryptSession10Container {
+		return nil, ErrFormat
+	}
+	l := int64(binary.LittleEndian.Uint32(buf[so+4:]))
+	if int64(so)+8+l > int64(len(buf)) {
+		return nil, ErrFormat
+	}
+	return &detection{scheme: RC4CryptoAPI, app: powerpoint, info: buf[so+8 : int64(so)+8+l], ppt: p}, nil
+}
+
+// powerPoint decrypts each persist object in the PowerPoint Document stream with the key for its persist ID
+func (d *Decrypter) powerPoint(f *mscfb.File) (*io.SectionReader, error) {
+	buf, err := ole.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	for id, off := range d.ppt.dir {
+		if id == d.ppt.session || int64(off)+8 > int64(len(buf)) {
+			continue
+		}
+		c, _ := rc4.NewCipher(d.key(id))
+		c.XORKeyStream(buf[off:off+8], buf[off:off+8])
+		end := int64(off) + 8 + int64(binary.LittleEndian.Uint32(buf[off+4:]))
+		if end > int64(len(buf)) {
+			return nil, ErrFormat
+		}
+		c.XORKeyStream(buf[off+8:end], buf[off+8:end])
+	}
+	return io.NewSectionReader(bytes.NewReader(buf), 0, int64(len(buf))), nil
+}
@@ -0,0 +1,256 @@
+// Copyright 2013 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package offcrypto decrypts password protected MS Office documents stored in compound files (https://learn.microsoft.com/en-us/openspecs/office_file_formats/ms-offcrypto).
+//
+// Legacy Word, Excel and PowerPoint documents encrypted with RC4 or RC4 CryptoAPI are supported,
+// as are OOXML packages (.docx, .xlsx, .pptx) encrypted with ECMA-376 Standard or Agile encryption.
+//
+// Example:
+//
+//	doc, _ := mscfb.New(file)
+//	dec, err := offcrypto.New(doc, "password")
+//	if err != nil {
+//	  log.Fatal(err)
+//	}
+//	for _, f := range doc.File {
+//	  if f.Name == "WordDocument" {
+//	    sr, _ := dec.Stream(f)
+//	    io.Copy(os.Stdout, sr)
+//	  }
+//	}
+//
+// For encrypted OOXML packages, the decrypted ZIP is returned by Package:
+//
+//	pkg, _ := dec.Package()
+//	zr, err := zip.NewReader(pkg, pkg.Size())
+package offcrypto
+
+import (
+	"errors"
+	"io"
+
+	"github.com/richardlehane/mscfb"
+)
+
+// Scheme is an encryption scheme
+type Scheme int
+
+const (
+	None         Scheme = iota // not encrypted
+	XOR                        // XOR obfuscation (detected but not decrypted)
+	RC4                        // Office binary document RC4 encryption
+	RC4CryptoAPI               // Office binary document RC4 CryptoAPI encryption
+	Standard                   // ECMA-376 Standard encryption
+	Agile                      // ECMA-376 Agile encryption
+)
+
+func (s Scheme) String() string {
+	switch s {
+	case None:
+		return "none"
+	case XOR:
+		return "XOR obfuscation"
+	case RC4:
+		return "RC4"
+	case RC4CryptoAPI:
+		return "RC4 CryptoAPI"
+	case Standard:
+		return "ECMA-376 Standard"
+	case Agile:
+		return "ECMA-376 Agile"
+	}
+	return "unknown"
+}
+
+var (
+	// ErrPassword is returned when the supplied password doesn't match the document's verifier
+	ErrPassword = errors.New("offcrypto: incorrect password")
+	// ErrUnsupported is returned for encryption schemes, or streams, that can't be decrypted
+	ErrUnsupported = errors.New("offcrypto: unsupported encryption")
+	// ErrFormat is returned when encryption structures are malformed
+	ErrFormat = errors.New("offcrypto: bad encryption header")
+)
+
+type app int
+
+const (
+	word app = iota + 1
+	excel
+	powerpoint
+)
+
+// Decrypter provides decrypted views of the streams in an encrypted document
+type Decrypter struct {
+	Scheme Scheme
+	app    app
+	r      *mscfb.Reader
+	key    func(block uint32) []byte
+	block  int64  // size of the blocks that are separately keyed
+	table  string // name of the Word table stream
+	lKey   int64  // length of the unencrypted encryption header at the start of the Word table stream
+	ppt    *persist
+	pkg    func(segment int64, b []byte) // decrypts a segment of an EncryptedPackage in place
+}
+
+type detection struct {
+	scheme Scheme
+	app    app
+	info   []byte // encryption header, starting with the version
+	table  string
+	lKey   int64
+	ppt    *persist
+}
+
+// Detect reports the encryption scheme used by a Word, Excel, PowerPoint or encrypted OOXML document.
+// Documents that aren't encrypted, or aren't recognised, return None.
+func Detect(r *mscfb.Reader) (Scheme, error) {
+	d, err := detect(r)
+	if err != nil || d == nil {
+		return None, err
+	}
+	return d.scheme, nil
+}
+
+func detect(r *mscfb.Reader) (*detection, error) {
+	if f := find(r, "EncryptionInfo"); f != nil && find(r, "EncryptedPackage") != nil {
+		return detectECMA(f)
+	}
+	if f := find(r, "WordDocument"); f != nil {
+		return detectWord(r, f)
+	}
+	if f := find(r, "Workbook"); f != nil {
+		return detectExcel(f)
+	}
+	if f := find(r, "Book"); f != nil {
+		return detectExcel(f)
+	}
+	if f := find(r, "Current User"); f != nil {
+		return detectPowerPoint(r, f)
+	}
+	return nil, nil
+}
+
+// New verifies the password for an encrypted document and returns a Decrypter for its streams
+func New(r *mscfb.Reader, password string) (*Decrypter, error) {
+	det, err := detect(r)
+	if err != nil {
+		return nil, err
+	}
+	if det == nil || det.scheme == None {
+		return &Decrypter{Scheme: None, r: r}, nil
+	}
+	switch det.scheme {
+	case XOR:
+		return nil, ErrUnsupported
+	case Standard, Agile:
+		verify := standardKey
+		if det.scheme == Agile {
+			verify = agileKey
+		}
+		pkg, err := verify(det.info, password)
+		if err != nil {
+			return nil, err
+		}
+		return &Decrypter{Scheme: det.scheme, r: r, pkg: pkg}, nil
+	}
+	key, err := rc4Key(det.info, password)
+	if err != nil {
+		return nil, err
+	}
+	d := &Decrypter{
+		Scheme: det.scheme,
+		app:    det.app,
+		r:      r,
+		key:    key,
+		block:  512,
+		table:  det.table,
+		lKey:   det.lKey,
+		ppt:    det.ppt,
+	}
+	if d.app == excel {
+		d.block = 1024
+	}
+	return d, nil
+}
+
+// Stream returns a decrypted view of a stream.
+// Streams that aren't encrypted in this document type are returned unaltered.
+// For ECMA-376 encryption, the EncryptedPackage stream is returned as the decrypted package.
+func (d *Decrypter) Stream(f *mscfb.File) (*io.SectionReader, error) {
+	if d.Scheme == None || len(f.Path) > 0 {
+		return io.NewSectionReader(f, 0, f.Size), nil
+	}
+	if d.pkg != nil && f.Name == "EncryptedPackage" {
+		return d.Package()
+	}
+	switch d.app {
+	case word:
+		switch f.Name {
+		case "WordDocument":
+			// the FibBase isn't encrypted
+			return d.blocks(f, [][2]int64{{0, 68}})
+		case d.table:
+			return d.blocks(f, [][2]int64{{0, d.lKey}})
+		case "Data":
+			return d.blocks(f, nil)
+		}
+	case excel:
+		if f.Name == "Workbook" || f.Name == "Book" {
+			plain, err := excelPlain(f)
+			if err != nil {
+				return nil, err
+			}
+			return d.blocks(f, plain)
+		}
+	case powerpoint:
+		switch f.Name {
+		case "PowerPoint Document":
+			return d.powerPoint(f)
+		case "Pictures":
+			if f.Size > 0 {
+				return nil, ErrUnsupported
+			}
+		}
+	}
+	return io.NewSectionReader(f, 0, f.Size), nil
+}
+
+// find returns the top-level stream with the given name
+func find(r *mscfb.Reader, name string) *mscfb.File {
+	for _, f := range r.File {
+		if len(f.Path) == 0 && f.Name == name && !f.FileInfo().IsDir() {
+			return f
+		}
+	}
+	return nil
+}
+
+// readAt reads exactly len(b) bytes at off, treating a short read as a format error
+func readAt(f *mscfb.File, b []byte, off int64) error {
+	if off < 0 || off+int64(len(b)) > f.Size {
+		return ErrFormat
+	}
+	if len(b) == 0 {
+		return nil
+	}
+	n, err := f.ReadAt(b, off)
+	if n == len(b) {
+		return nil
+	}
+	if err == nil || err == io.EOF {
+		return ErrFormat
+	}
+	return err
+}
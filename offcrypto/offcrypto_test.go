@@ -0,0 +1,255 @@
+package offcrypto
+
+import (
+	"bytes"
+	"crypto/rc4"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/richardlehane/mscfb"
+	"github.com/richardlehane/mscfb/internal/ole"
+	"github.com/richardlehane/mscfb/mscfbtest"
+)
+
+const password = "Password1234_"
+
+var salt = []byte("0123456789abcdef")
+
+func text(n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = "the quick brown fox "[i%20]
+	}
+	return b
+}
+
+// encrypt is the inverse of blockReader: it keys each block afresh and leaves plain ranges alone
+func encrypt(b []byte, key func(uint32) []byte, block int, plain [][2]int64) []byte {
+	out := make([]byte, len(b))
+	for i := 0; i < len(b); i += block {
+		e := i + block
+		if e > len(b) {
+			e = len(b)
+		}
+		c, _ := rc4.NewCipher(key(uint32(i / block)))
+		c.XORKeyStream(out[i:e], b[i:e])
+	}
+	for _, r := range plain {
+		copy(out[r[0]:r[0]+r[1]], b[r[0]:r[0]+r[1]])
+	}
+	return out
+}
+
+func unhex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// the encrypted verifiers and keys below were produced by an independent implementation of MS-OFFCRYPTO
+// for password and salt, with the verifier "verifier........"
+
+func rc4Info() ([]byte, func(uint32) []byte) {
+	info := []byte{1, 0, 1, 0}
+	info = append(info, salt...)
+	info = append(info, unhex("1ad86244c1e35a36690dbf96bc407c259b25ae3a8426272c506511a2f50b19af")...)
+	return info, binaryKey(salt, ole.EncodeUTF16(password))
+}
+
+func cryptoAPIInfo() ([]byte, func(uint32) []byte) {
+	info := make([]byte, 12+34)
+	binary.LittleEndian.PutUint16(info, 4)
+	binary.LittleEndian.PutUint16(info[2:], 2)
+	binary.LittleEndian.PutUint32(info[8:], 34)
+	binary.LittleEndian.PutUint32(info[20:], algRC4)
+	binary.LittleEndian.PutUint32(info[24:], algSHA1)
+	binary.LittleEndian.PutUint32(info[28:], 128)
+	info = append(info, 16, 0, 0, 0)
+	info = append(info, salt...)
+	enc := unhex("7ce6f88d4d1ecb6c3b7e952511026f737e32b74831c201c96337d0d57b3a8cd9918ccc84")
+	info = append(info, enc[:16]...)
+	info = append(info, 20, 0, 0, 0)
+	return append(info, enc[16:]...), cryptoAPIKey(salt, ole.EncodeUTF16(password), 16)
+}
+
+func TestKeys(t *testing.T) {
+	pw := ole.EncodeUTF16(password)
+	for i, test := range []struct {
+		key   func(uint32) []byte
+		block uint32
+		want  string
+	}{
+		{binaryKey(salt, pw), 0, "b53c1fa9dc73e1146b1536b56b4e2f53"},
+		{binaryKey(salt, pw), 1, "f8baac0ebfc33f176b39588191803d8d"},
+		{cryptoAPIKey(salt, pw, 5), 0, "304dd9e1e70000000000000000000000"},
+		{cryptoAPIKey(salt, pw, 16), 0, "304dd9e1e768b9bb46d1ad3bcba07423"},
+		{cryptoAPIKey(salt, pw, 16), 1, "f284af74aded47a9fdc514c87eff49eb"},
+	} {
+		if got := hex.EncodeToString(test.key(test.block)); got != test.want {
+			t.Errorf("%d: expecting key %s, got %s", i, test.want, got)
+		}
+	}
+}
+
+func open(t *testing.T, b *mscfbtest.Builder) *mscfb.Reader {
+	doc, err := b.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := mscfb.New(bytes.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func check(t *testing.T, r *mscfb.Reader, streams map[string][]byte) {
+	if _, err := New(r, "wrong"); err != ErrPassword {
+		t.Fatalf("expecting a password error, got %v", err)
+	}
+	d, err := New(r, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, expect := range streams {
+		sr, err := d.Stream(find(r, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(sr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, expect) {
+			t.Errorf("bad decryption of %s", name)
+		}
+	}
+}
+
+func TestWord(t *testing.T) {
+	info, key := rc4Info()
+	wd := text(5000)
+	binary.LittleEndian.PutUint16(wd, 0xA5EC)
+	binary.LittleEndian.PutUint16(wd[10:], 0x0100|0x0200)
+	binary.LittleEndian.PutUint32(wd[14:], uint32(len(info)))
+	table := append(append([]byte{}, info...), text(700)...)
+	b := mscfbtest.New()
+	b.Stream("WordDocument", encrypt(wd, key, 512, [][2]int64{{0, 68}}))
+	b.Stream("1Table", encrypt(table, key, 512, [][2]int64{{0, int64(len(info))}}))
+	b.Stream("Data", encrypt(text(300), key, 512, nil))
+	r := open(t, b)
+	if s, err := Detect(r); s != RC4 || err != nil {
+		t.Fatalf("expecting RC4, got %s %v", s, err)
+	}
+	check(t, r, map[string][]byte{"WordDocument": wd, "1Table": table, "Data": text(300)})
+}
+
+func TestWordKeyLength(t *testing.T) {
+	wd := text(5000)
+	binary.LittleEndian.PutUint16(wd, 0xA5EC)
+	binary.LittleEndian.PutUint16(wd[10:], 0x0100)
+	binary.LittleEndian.PutUint32(wd[14:], 0xFFFFFFF0) // larger than the table stream
+	b := mscfbtest.New()
+	b.Stream("WordDocument", wd)
+	b.Stream("0Table", text(700))
+	if _, err := Detect(open(t, b)); err != ErrFormat {
+		t.Errorf("expecting a format error, got %v", err)
+	}
+}
+
+func record(typ uint16, data []byte) []byte {
+	r := make([]byte, 4, 4+len(data))
+	binary.LittleEndian.PutUint16(r, typ)
+	binary.LittleEndian.PutUint16(r[2:], uint16(len(data)))
+	return append(r, data...)
+}
+
+func TestExcel(t *testing.T) {
+	info, key := cryptoAPIInfo()
+	var wb []byte
+	var plain [][2]int64
+	for _, rec := range [][]byte{
+		record(recBOF, make([]byte, 16)),
+		record(recFilePass, append([]byte{1, 0}, info...)),
+		record(recBoundSheet, append([]byte{1, 2, 3, 4}, text(20)...)),
+		record(0x00FC, text(2000)),
+		record(recEOF, nil),
+	} {
+		plain = append(plain, [2]int64{int64(len(wb)), 4})
+		switch binary.LittleEndian.Uint16(rec) {
+		case recBOF, recFilePass:
+			plain = append(plain, [2]int64{int64(len(wb)) + 4, int64(len(rec)) - 4})
+		case recBoundSheet:
+			plain = append(plain, [2]int64{int64(len(wb)) + 4, 4})
+		}
+		wb = append(wb, rec...)
+	}
+	b := mscfbtest.New()
+	b.Stream("Workbook", encrypt(wb, key, 1024, plain))
+	r := open(t, b)
+	if s, err := Detect(r); s != RC4CryptoAPI || err != nil {
+		t.Fatalf("expecting RC4 CryptoAPI, got %s %v", s, err)
+	}
+	check(t, r, map[string][]byte{"Workbook": wb})
+}
+
+func TestPowerPoint(t *testing.T) {
+	info, key := cryptoAPIInfo()
+	// a slide (persist ID 1), the crypt session (persist ID 2), the persist directory and a user edit
+	slide := append(make([]byte, 8), text(100)...)
+	binary.LittleEndian.PutUint16(slide[2:], 0x03EE)
+	binary.LittleEndian.PutUint32(slide[4:], 100)
+	var doc []byte
+	doc = append(doc, slide...)
+	session := make([]byte, 8, 8+len(info))
+	binary.LittleEndian.PutUint16(session[2:], rtCryptSession10Container)
+	binary.LittleEndian.PutUint32(session[4:], uint32(len(info)))
+	doc = append(doc, append(session, info...)...)
+	pd := make([]byte, 20)
+	binary.LittleEndian.PutUint16(pd[2:], rtPersistDirectoryAtom)
+	binary.LittleEndian.PutUint32(pd[4:], 12)
+	binary.LittleEndian.PutUint32(pd[8:], 1|2<<20)
+	binary.LittleEndian.PutUint32(pd[12:], 0)
+	binary.LittleEndian.PutUint32(pd[16:], uint32(len(slide)))
+	pdOff := len(doc)
+	doc = append(doc, pd...)
+	ue := make([]byte, 40)
+	binary.LittleEndian.PutUint16(ue[2:], rtUserEditAtom)
+	binary.LittleEndian.PutUint32(ue[4:], 0x20)
+	binary.LittleEndian.PutUint32(ue[20:], uint32(pdOff))
+	binary.LittleEndian.PutUint32(ue[36:], 2)
+	ueOff := len(doc)
+	doc = append(doc, ue...)
+	enc := append([]byte{}, doc...)
+	c, _ := rc4.NewCipher(key(1))
+	c.XORKeyStream(enc[:len(slide)], slide)
+	cu := make([]byte, 20)
+	binary.LittleEndian.PutUint32(cu[12:], encryptedToken)
+	binary.LittleEndian.PutUint32(cu[16:], uint32(ueOff))
+	b := mscfbtest.New()
+	b.Stream("Current User", cu).Stream("PowerPoint Document", enc)
+	r := open(t, b)
+	if s, err := Detect(r); s != RC4CryptoAPI || err != nil {
+		t.Fatalf("expecting RC4 CryptoAPI, got %s %v", s, err)
+	}
+	check(t, r, map[string][]byte{"PowerPoint Document": doc})
+}
+
+func TestUnencrypted(t *testing.T) {
+	for _, p := range []string{"../test/test.doc", "../test/test.xls", "../test/test.ppt"} {
+		file, _ := os.Open(p)
+		defer file.Close()
+		r, err := mscfb.New(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s, err := Detect(r); s != None || err != nil {
+			t.Errorf("%s: expecting no encryption, got %s %v", p, s, err)
+		}
+	}
+}
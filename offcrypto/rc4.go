@@ -0,0 +1,213 @@
+// Copyright 2013 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package offcrypto
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/rc4"
+	"crypto/sha1"
+	"encoding/binary"
+	"io"
+
+	"github.com/richardlehane/mscfb"
+	"github.com/richardlehane/mscfb/internal/ole"
+)
+
+const (
+	algRC4  uint32 = 0x6801
+	algSHA1 uint32 = 0x8004
+)
+
+// encryptionHeader holds the EncryptionHeader and EncryptionVerifier structures shared by RC4 CryptoAPI and ECMA-376 Standard encryption
+type encryptionHeader struct {
+	flags        uint32
+	algID        uint32
+	algIDHash    uint32
+	keySize      uint32 // in bits
+	salt         []byte
+	verifier     []byte // encrypted
+	verifierHash []byte // encrypted
+	hashSize     uint32
+}
+
+// parseHeader parses an EncryptionHeader and EncryptionVerifier, b starts after the version
+func parseHeader(b []byte) (*encryptionHeader, error) {
+	if len(b) < 8 {
+		return nil, ErrFormat
+	}
+	sz := binary.LittleEndian.Uint32(b[4:])
+	b = b[8:]
+	if sz < 32 || uint64(sz) > uint64(len(b)) {
+		return nil, ErrFormat
+	}
+	h := &encryptionHeader{
+		flags:     binary.LittleEndian.Uint32(b),
+		algID:     binary.LittleEndian.Uint32(b[8:]),
+		algIDHash: binary.LittleEndian.Uint32(b[12:]),
+		keySize:   binary.LittleEndian.Uint32(b[16:]),
+	}
+	b = b[sz:]
+	if len(b) < 40 {
+		return nil, ErrFormat
+	}
+	saltSize := binary.LittleEndian.Uint32(b)
+	if saltSize != 16 {
+		return nil, ErrFormat
+	}
+	h.salt = b[4:20]
+	h.verifier = b[20:36]
+	h.hashSize = binary.LittleEndian.Uint32(b[36:])
+	h.verifierHash = b[40:]
+	if h.hashSize > uint32(len(h.verifierHash)) {
+		return nil, ErrFormat
+	}
+	return h, nil
+}
+
+// rc4Key verifies the password against an RC4 or RC4 CryptoAPI encryption header (starting with its version) and
+// returns a function deriving the key for each block
+func rc4Key(info []byte, password string) (func(uint32) []byte, error) {
+	if len(info) < 4 {
+		return nil, ErrFormat
+	}
+	pw := ole.EncodeUTF16(password)
+	major, minor := binary.LittleEndian.Uint16(info), binary.LittleEndian.Uint16(info[2:])
+	if major == 1 && minor == 1 {
+		if len(info) < 52 {
+			return nil, ErrFormat
+		}
+		verifier, hash := info[20:36], info[36:52]
+		key := binaryKey(info[4:20], pw)
+		c, _ := rc4.NewCipher(key(0))
+		dec := make([]byte, 32)
+		c.XORKeyStream(dec[:16], verifier)
+		c.XORKeyStream(dec[16:], hash)
+		if vh := md5.Sum(dec[:16]); !bytes.Equal(vh[:], dec[16:]) {
+			return nil, ErrPassword
+		}
+		return key, nil
+	}
+	if (major < 2 || major > 4) || minor != 2 {
+		return nil, ErrUnsupported
+	}
+	h, err := parseHeader(info[4:])
+	if err != nil {
+		return nil, err
+	}
+	if h.algID != algRC4 || (h.algIDHash != algSHA1 && h.algIDHash != 0) || h.hashSize != sha1.Size {
+		return nil, ErrUnsupported
+	}
+	ks := h.keySize / 8
+	if ks == 0 {
+		ks = 5
+	}
+	if ks < 5 || ks > sha1.Size {
+		return nil, ErrFormat
+	}
+	key := cryptoAPIKey(h.salt, pw, ks)
+	c, _ := rc4.NewCipher(key(0))
+	dec := make([]byte, 16+sha1.Size)
+	c.XORKeyStream(dec[:16], h.verifier)
+	c.XORKeyStream(dec[16:], h.verifierHash[:sha1.Size])
+	if vh := sha1.Sum(dec[:16]); !bytes.Equal(vh[:], dec[16:]) {
+		return nil, ErrPassword
+	}
+	return key, nil
+}
+
+// binaryKey derives RC4 block keys from an MD5 hash of the password and salt (MS-OFFCRYPTO 2.3.6.2)
+func binaryKey(salt, pw []byte) func(uint32) []byte {
+	h0 := md5.Sum(pw)
+	buf := make([]byte, 0, 16*21)
+	for i := 0; i < 16; i++ {
+		buf = append(buf, h0[:5]...)
+		buf = append(buf, salt...)
+	}
+	h1 := md5.Sum(buf)
+	return func(block uint32) []byte {
+		b := make([]byte, 9)
+		copy(b, h1[:5])
+		binary.LittleEndian.PutUint32(b[5:], block)
+		k := md5.Sum(b)
+		return k[:]
+	}
+}
+
+// cryptoAPIKey derives RC4 block keys of ks bytes from a SHA-1 hash of the salt and password (MS-OFFCRYPTO 2.3.5.2)
+func cryptoAPIKey(salt, pw []byte, ks uint32) func(uint32) []byte {
+	h0 := sha1.Sum(append(append([]byte{}, salt...), pw...))
+	return func(block uint32) []byte {
+		b := make([]byte, sha1.Size+4)
+		copy(b, h0[:])
+		binary.LittleEndian.PutUint32(b[sha1.Size:], block)
+		k := sha1.Sum(b)
+		if ks == 5 {
+			// 40 bit keys are padded to 128 bits
+			return append(k[:5], make([]byte, 11)...)
+		}
+		return k[:ks]
+	}
+}
+
+// blocks returns a view of f decrypted with a fresh RC4 key for each block.
+// Plain lists ranges (offset, length) that are stored unencrypted.
+func (d *Decrypter) blocks(f *mscfb.File, plain [][2]int64) (*io.SectionReader, error) {
+	return io.NewSectionReader(&blockReader{f: f, key: d.key, block: d.block, plain: plain}, 0, f.Size), nil
+}
+
+type blockReader struct {
+	f     *mscfb.File
+	key   func(uint32) []byte
+	block int64
+	plain [][2]int64
+}
+
+func (br *blockReader) ReadAt(p []byte, off int64) (int, error) {
+	if off >= br.f.Size {
+		return 0, io.EOF
+	}
+	enc := make([]byte, len(p))
+	n, err := br.f.ReadAt(enc, off)
+	if n == 0 {
+		return 0, err
+	}
+	for pos := off; pos < off+int64(n); {
+		blk := pos / br.block
+		end := (blk + 1) * br.block
+		if end > off+int64(n) {
+			end = off + int64(n)
+		}
+		c, _ := rc4.NewCipher(br.key(uint32(blk)))
+		skip := make([]byte, pos-blk*br.block)
+		c.XORKeyStream(skip, skip)
+		c.XORKeyStream(p[pos-off:end-off], enc[pos-off:end-off])
+		pos = end
+	}
+	// restore the unencrypted ranges
+	for _, r := range br.plain {
+		s, e := r[0], r[0]+r[1]
+		if s < off {
+			s = off
+		}
+		if e > off+int64(n) {
+			e = off + int64(n)
+		}
+		if s < e {
+			copy(p[s-off:e-off], enc[s-off:e-off])
+		}
+	}
+	return n, err
+}
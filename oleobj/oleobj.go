@@ -0,0 +1,225 @@
+// Copyright 2013 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package oleobj extracts files embedded as OLE objects in compound files.
+//
+// Embedded objects are found in \x01Ole10Native streams (files embedded with the Packager), and in Package and CONTENTS
+// streams (embedded OOXML documents and other objects that store their native data directly). These streams appear
+// in ObjectPool storages of Word documents, in the storages of Excel and PowerPoint embeddings, and in the attachment
+// storages of Outlook MSG files.
+//
+// Example:
+//
+//	doc, _ := mscfb.New(file)
+//	objects, err := oleobj.Find(doc)
+//	if err != nil {
+//	  log.Fatal(err)
+//	}
+//	for _, o := range objects {
+//	  fmt.Println(o.Filename, o.Size)
+//	  io.Copy(out, o.Reader())
+//	}
+package oleobj
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/richardlehane/mscfb"
+	"github.com/richardlehane/mscfb/internal/ole"
+)
+
+// Kind is the kind of stream an object was extracted from
+type Kind int
+
+const (
+	Native   Kind = iota // \x01Ole10Native stream
+	Package              // Package stream
+	Contents             // CONTENTS stream
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Package:
+		return "Package"
+	case Contents:
+		return "CONTENTS"
+	}
+	return "Ole10Native"
+}
+
+// Object is an embedded object. Filename, Source and Temp are only available for Packager objects in
+// \x01Ole10Native streams.
+type Object struct {
+	Kind     Kind
+	Path     []string // path to the stream
+	Filename string   // original filename (the Packager label)
+	Source   string   // path of the original file when it was embedded
+	Temp     string   // temporary path used by the Packager
+	Size     int64
+	file     *mscfb.File
+	offset   int64
+}
+
+// Reader returns the payload of the object
+func (o *Object) Reader() io.Reader {
+	return io.NewSectionReader(o.file, o.offset, o.Size)
+}
+
+// ErrFormat is returned for malformed \x01Ole10Native streams
+var ErrFormat = errors.New("oleobj: bad Ole10Native stream")
+
+// ErrNotObject is returned by Parse for streams that don't hold embedded objects
+var ErrNotObject = errors.New("oleobj: not an embedded object stream")
+
+const (
+	nativeName   = "Ole10Native" // the leading \x01 is held in File.Initial
+	packageName  = "Package"
+	contentsName = "CONTENTS"
+)
+
+// Find returns the embedded objects in a compound file
+func Find(r *mscfb.Reader) ([]*Object, error) {
+	var ret []*Object
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		o, err := Parse(f)
+		if err == ErrNotObject {
+			continue
+		}
+		if err != nil {
+			return ret, err
+		}
+		ret = append(ret, o)
+	}
+	return ret, nil
+}
+
+// Parse reads an embedded object from a \x01Ole10Native, Package or CONTENTS stream
+func Parse(f *mscfb.File) (*Object, error) {
+	o := &Object{Path: append(append([]string{}, f.Path...), f.Name), file: f}
+	switch f.Name {
+	case nativeName:
+		if f.Initial != 1 {
+			return nil, ErrNotObject
+		}
+	case packageName:
+		o.Kind, o.Size = Package, f.Size
+		return o, nil
+	case contentsName:
+		o.Kind, o.Size = Contents, f.Size
+		return o, nil
+	default:
+		return nil, ErrNotObject
+	}
+	if f.Size < 4 {
+		return nil, ErrFormat
+	}
+	// the payload of a Packager object follows its header, so only the header is read here
+	hdrLen := f.Size
+	if hdrLen > 4096 {
+		hdrLen = 4096
+	}
+	buf := make([]byte, hdrLen)
+	if n, err := f.ReadAt(buf, 0); n < len(buf) {
+		if err == nil {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	total := int64(binary.LittleEndian.Uint32(buf)) + 4
+	if total > f.Size {
+		total = f.Size
+	}
+	if !o.packager(buf, total) {
+		// not a Packager object: the native data follows the size
+		o.Filename, o.Source, o.Temp = "", "", ""
+		o.offset, o.Size = 4, total-4
+	}
+	return o, nil
+}
+
+// packager parses the Packager header: type, label, source path, flags, temp path and payload size.
+// It reports false if the stream doesn't hold a file embedded by the Packager.
+func (o *Object) packager(buf []byte, total int64) bool {
+	b := buf[4:]
+	if len(b) < 2 || binary.LittleEndian.Uint16(b) != 2 {
+		return false
+	}
+	b = b[2:]
+	var ok bool
+	if o.Filename, b, ok = cstring(b); !ok {
+		return false
+	}
+	if o.Source, b, ok = cstring(b); !ok {
+		return false
+	}
+	// two reserved bytes, then the object type: 3 for an embedded file, 1 for a link
+	if len(b) < 8 || binary.LittleEndian.Uint16(b[2:]) != 3 {
+		return false
+	}
+	tl := int(binary.LittleEndian.Uint32(b[4:]))
+	b = b[8:]
+	if tl > len(b) || len(b)-tl < 4 {
+		return false
+	}
+	o.Temp, _, _ = cstring(b[:tl])
+	b = b[tl:]
+	o.Size = int64(binary.LittleEndian.Uint32(b))
+	o.offset = int64(len(buf)-len(b)) + 4
+	if o.offset+o.Size > total {
+		return false
+	}
+	o.unicode(total - o.offset - o.Size)
+	return true
+}
+
+// unicode reads the optional Unicode temp path, label and source path that may follow the payload
+func (o *Object) unicode(avail int64) {
+	if avail <= 0 {
+		return
+	}
+	start := o.offset + o.Size
+	b := make([]byte, avail)
+	if n, _ := o.file.ReadAt(b, start); int64(n) < avail {
+		return
+	}
+	var strs [3]string
+	for i := range strs {
+		if len(b) < 4 {
+			return
+		}
+		l := int(binary.LittleEndian.Uint32(b)) * 2
+		if l > len(b)-4 {
+			return
+		}
+		strs[i] = ole.UTF16(b[4 : 4+l])
+		b = b[4+l:]
+	}
+	if strs[1] != "" {
+		o.Temp, o.Filename, o.Source = strs[0], strs[1], strs[2]
+	}
+}
+
+func cstring(b []byte) (string, []byte, bool) {
+	for i, c := range b {
+		if c == 0 {
+			return ole.Windows1252(b[:i]), b[i+1:], true
+		}
+	}
+	return "", nil, false
+}
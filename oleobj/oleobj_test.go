@@ -0,0 +1,62 @@
+package oleobj
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/richardlehane/mscfb"
+	"github.com/richardlehane/mscfb/internal/oletest"
+	"github.com/richardlehane/mscfb/mscfbtest"
+)
+
+func TestFind(t *testing.T) {
+	exe := append([]byte("MZ"), bytes.Repeat([]byte{0x90}, 5000)...)
+	zip := []byte("PK\x03\x04 a docx")
+	b := mscfbtest.New()
+	b.Stream("WordDocument", make([]byte, 100))
+	pool := b.Storage("ObjectPool")
+	pool.Storage("_1000").Stream("\x01Ole10Native", oletest.Native("evil.exe", "C:\\Users\\x\\evil.exe", exe, ""))
+	pool.Storage("_1001").Stream("\x01Ole10Native", oletest.Native("r?sum?.txt", "C:\\r?sum?.txt", []byte("hello"), "résumé.txt"))
+	pool.Storage("_1002").Stream("Package", zip)
+	pool.Storage("_1003").Stream("\x01Ole10Native", append(oletest.U32(3), "raw"...))
+	doc, err := b.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := mscfb.New(bytes.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	objs, err := Find(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objs) != 4 {
+		t.Fatalf("expecting 4 objects, got %d", len(objs))
+	}
+	expect := []struct {
+		kind     Kind
+		filename string
+		source   string
+		payload  []byte
+	}{
+		{Native, "evil.exe", "C:\\Users\\x\\evil.exe", exe},
+		{Native, "résumé.txt", "C:\\src\\x", []byte("hello")},
+		{Package, "", "", zip},
+		{Native, "", "", []byte("raw")},
+	}
+	for i, o := range objs {
+		e := expect[i]
+		got, err := io.ReadAll(o.Reader())
+		if err != nil || o.Kind != e.kind || o.Filename != e.filename || o.Source != e.source || !bytes.Equal(got, e.payload) {
+			t.Errorf("%d: expecting %v %q %q, got %v %q %q (%d bytes, %v)", i, e.kind, e.filename, e.source, o.Kind, o.Filename, o.Source, len(got), err)
+		}
+		if len(o.Path) != 3 || o.Path[0] != "ObjectPool" {
+			t.Errorf("%d: bad path %v", i, o.Path)
+		}
+	}
+	if _, err := Parse(r.File[1]); err != ErrNotObject {
+		t.Errorf("expecting ErrNotObject, got %v", err)
+	}
+}
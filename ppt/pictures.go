@@ -0,0 +1,102 @@
+// Copyright 2013 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ppt
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+
+	"github.com/richardlehane/mscfb/internal/ole"
+)
+
+// Picture is an image in the Pictures stream
+type Picture struct {
+	Type     string // emf, wmf, pict, jpeg, png, dib or tiff
+	Offset   int64  // offset of the blip record in the Pictures stream
+	data     []byte
+	deflated bool // metafile data compressed with DEFLATE
+}
+
+// blip types with the instances that have a single UID. Instances one higher have a second UID.
+var blips = map[uint16]struct {
+	typ      string
+	instance uint16
+}{
+	0xF01A: {"emf", 0x3D4},
+	0xF01B: {"wmf", 0x216},
+	0xF01C: {"pict", 0x542},
+	0xF01D: {"jpeg", 0x46A},
+	0xF01E: {"png", 0x6E0},
+	0xF01F: {"dib", 0x7A8},
+	0xF029: {"tiff", 0x6E4},
+	0xF02A: {"jpeg", 0x6E2},
+}
+
+const metafileHeaderSize = 34
+
+// Bytes returns the image data, decompressing metafiles. DIB data lacks a bitmap file header.
+func (p *Picture) Bytes() ([]byte, error) {
+	if !p.deflated {
+		return p.data, nil
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(p.data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
+
+// Pictures returns the images in the Pictures stream
+func (p *Presentation) Pictures() ([]*Picture, error) {
+	if p.pictures == nil || p.pictures.Size == 0 {
+		return nil, nil
+	}
+	b, err := ole.ReadAll(p.pictures)
+	if err != nil {
+		return nil, err
+	}
+	recs, err := ParseRecords(b, 0)
+	ret := make([]*Picture, 0, len(recs))
+	for _, r := range recs {
+		blip, ok := blips[r.Type]
+		if !ok || r.Container() {
+			continue
+		}
+		pic := &Picture{Type: blip.typ, Offset: r.Offset}
+		off := 16 // rgbUid1
+		if r.Instance == blip.instance+1 {
+			off += 16
+		}
+		switch blip.typ {
+		case "emf", "wmf", "pict":
+			if len(r.Data) < off+metafileHeaderSize {
+				return ret, ErrFormat
+			}
+			// compression method is the 33rd byte of the header: 0 for DEFLATE, 0xFE for none
+			pic.deflated = r.Data[off+32] == 0
+			off += metafileHeaderSize
+		default:
+			off++ // tag
+		}
+		if len(r.Data) < off {
+			return ret, ErrFormat
+		}
+		pic.data = r.Data[off:]
+		ret = append(ret, pic)
+	}
+	return ret, err
+}
@@ -0,0 +1,267 @@
+// Copyright 2013 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ppt extracts slide text, notes and pictures from PowerPoint 97-2003 presentations.
+//
+// The Current User stream locates the latest UserEditAtom in the PowerPoint Document stream. The chain of edits
+// gives the persist directory, which maps persist IDs to the offsets of the document, slide and notes records.
+//
+// Example:
+//
+//	cfb, _ := mscfb.New(file)
+//	p, err := ppt.New(cfb)
+//	if err != nil {
+//	  log.Fatal(err)
+//	}
+//	for i, s := range p.Slides {
+//	  fmt.Println(i+1, strings.Join(s.Text, "\n"), strings.Join(s.Notes, "\n"))
+//	}
+package ppt
+
+import (
+	"encoding/binary"
+	"errors"
+	"strings"
+
+	"github.com/richardlehane/mscfb"
+	"github.com/richardlehane/mscfb/internal/ole"
+)
+
+var (
+	// ErrFormat is returned for compound files that aren't PowerPoint presentations, and for malformed presentations
+	ErrFormat = errors.New("ppt: bad PowerPoint presentation")
+	// ErrEncrypted is returned for encrypted presentations
+	ErrEncrypted = errors.New("ppt: presentation is encrypted")
+)
+
+const (
+	headerToken          = 0xE391C05F
+	encryptedHeaderToken = 0xF3D1C4DF
+)
+
+// Slide is a slide with its text and the text of its notes page. Each string is the text of a text box or
+// placeholder, with paragraphs separated by \r.
+type Slide struct {
+	ID    uint32
+	Text  []string
+	Notes []string
+}
+
+// Presentation is a PowerPoint presentation
+type Presentation struct {
+	Slides   []Slide
+	stream   []byte
+	persist  map[uint32]uint32 // persist ID to stream offset
+	pictures *mscfb.File
+}
+
+// New reads the slides of a presentation
+func New(r *mscfb.Reader) (*Presentation, error) {
+	var doc, cu *mscfb.File
+	p := &Presentation{persist: make(map[uint32]uint32)}
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() || len(f.Path) > 0 {
+			continue
+		}
+		switch f.Name {
+		case "PowerPoint Document":
+			doc = f
+		case "Current User":
+			cu = f
+		case "Pictures":
+			p.pictures = f
+		}
+	}
+	if doc == nil || cu == nil {
+		return nil, ErrFormat
+	}
+	cub, err := ole.ReadAll(cu)
+	if err != nil {
+		return nil, err
+	}
+	if len(cub) < headerSize+12 || parseHeader(cub).Type != RecCurrentUserAtom {
+		return nil, ErrFormat
+	}
+	switch binary.LittleEndian.Uint32(cub[headerSize+4:]) {
+	case headerToken:
+	case encryptedHeaderToken:
+		return nil, ErrEncrypted
+	default:
+		return nil, ErrFormat
+	}
+	if p.stream, err = ole.ReadAll(doc); err != nil {
+		return nil, err
+	}
+	docRef, err := p.edits(binary.LittleEndian.Uint32(cub[headerSize+8:]))
+	if err != nil {
+		return nil, err
+	}
+	document, err := p.record(docRef)
+	if err != nil {
+		return nil, err
+	}
+	if document.Type != RecDocument {
+		return nil, ErrFormat
+	}
+	p.slides(document)
+	return p, nil
+}
+
+// edits follows the chain of UserEditAtoms from the latest, building the persist directory. It returns the persist
+// ID of the document container.
+func (p *Presentation) edits(off uint32) (uint32, error) {
+	var docRef uint32
+	seen := make(map[uint32]bool)
+	for first := true; first || off != 0; first = false {
+		if seen[off] {
+			return 0, ErrFormat
+		}
+		seen[off] = true
+		edit, err := p.atom(off, RecUserEditAtom, 20)
+		if err != nil {
+			return 0, err
+		}
+		if first {
+			docRef = binary.LittleEndian.Uint32(edit[16:])
+		}
+		dir, err := p.atom(binary.LittleEndian.Uint32(edit[12:]), RecPersistDirectoryAtom, 0)
+		if err != nil {
+			return 0, err
+		}
+		// entries: a persist ID (20 bits) and count (12 bits) followed by count offsets
+		for len(dir) >= 4 {
+			v := binary.LittleEndian.Uint32(dir)
+			id, n := v&0xFFFFF, int(v>>20)
+			if len(dir) < 4+n*4 {
+				return 0, ErrFormat
+			}
+			for i := 0; i < n; i++ {
+				// later edits take precedence
+				if _, ok := p.persist[id+uint32(i)]; !ok {
+					p.persist[id+uint32(i)] = binary.LittleEndian.Uint32(dir[4+i*4:])
+				}
+			}
+			dir = dir[4+n*4:]
+		}
+		off = binary.LittleEndian.Uint32(edit[8:])
+	}
+	return docRef, nil
+}
+
+// atom returns the data of an atom of a given type and minimum size at an offset in the stream
+func (p *Presentation) atom(off uint32, typ uint16, size int) ([]byte, error) {
+	if int64(off)+headerSize > int64(len(p.stream)) {
+		return nil, ErrFormat
+	}
+	h := parseHeader(p.stream[off:])
+	end := int64(off) + headerSize + int64(h.Length)
+	if h.Type != typ || end > int64(len(p.stream)) || int(h.Length) < size {
+		return nil, ErrFormat
+	}
+	return p.stream[off+headerSize : end], nil
+}
+
+// record parses the record with a persist ID
+func (p *Presentation) record(id uint32) (*Record, error) {
+	off, ok := p.persist[id]
+	if !ok || int64(off)+headerSize > int64(len(p.stream)) {
+		return nil, ErrFormat
+	}
+	end := int64(off) + headerSize + int64(parseHeader(p.stream[off:]).Length)
+	if end > int64(len(p.stream)) {
+		return nil, ErrFormat
+	}
+	recs, err := ParseRecords(p.stream[off:end], int64(off))
+	if err != nil || len(recs) != 1 {
+		return nil, ErrFormat
+	}
+	return recs[0], nil
+}
+
+// slideList is an entry in a SlideListWithText container: a slide or notes persist ID, and the text that follows
+type slideList struct {
+	persist uint32
+	id      uint32
+	text    []string
+}
+
+func lists(document *Record, instance uint16) []slideList {
+	var ret []slideList
+	for _, c := range document.Children {
+		if c.Type != RecSlideListWithText || c.Instance != instance {
+			continue
+		}
+		for _, a := range c.Children {
+			switch a.Type {
+			case RecSlidePersistAtom:
+				if len(a.Data) >= 16 {
+					ret = append(ret, slideList{
+						persist: binary.LittleEndian.Uint32(a.Data),
+						id:      binary.LittleEndian.Uint32(a.Data[12:]),
+					})
+				}
+			case RecTextCharsAtom, RecTextBytesAtom:
+				if len(ret) > 0 {
+					ret[len(ret)-1].text = append(ret[len(ret)-1].text, text(a))
+				}
+			}
+		}
+	}
+	return ret
+}
+
+func (p *Presentation) slides(document *Record) {
+	notes := make(map[uint32]slideList)
+	for _, n := range lists(document, 2) {
+		notes[n.id] = n
+	}
+	for _, s := range lists(document, 0) {
+		slide := Slide{ID: s.id, Text: s.text}
+		rec, err := p.record(s.persist)
+		if err != nil || rec.Type != RecSlide {
+			p.Slides = append(p.Slides, slide)
+			continue
+		}
+		slide.Text = append(slide.Text, drawingText(rec)...)
+		if sa := rec.Find(RecSlideAtom); sa != nil && len(sa.Data) >= 20 {
+			if n, ok := notes[binary.LittleEndian.Uint32(sa.Data[16:])]; ok {
+				slide.Notes = n.text
+				if nrec, err := p.record(n.persist); err == nil && nrec.Type == RecNotes {
+					slide.Notes = append(slide.Notes, drawingText(nrec)...)
+				}
+			}
+		}
+		p.Slides = append(p.Slides, slide)
+	}
+}
+
+// drawingText returns the text of the text atoms in a slide or notes container: the text of text boxes and of
+// placeholders whose text isn't held in the SlideListWithText
+func drawingText(rec *Record) []string {
+	var ret []string
+	rec.Walk(func(r *Record) {
+		if r.Type == RecTextCharsAtom || r.Type == RecTextBytesAtom {
+			ret = append(ret, text(r))
+		}
+	})
+	return ret
+}
+
+// text decodes a TextCharsAtom (UTF-16) or TextBytesAtom (the low bytes of UTF-16 characters)
+func text(r *Record) string {
+	if r.Type == RecTextBytesAtom {
+		return ole.Latin1(r.Data)
+	}
+	return strings.TrimRight(ole.UTF16(r.Data), "\x00")
+}
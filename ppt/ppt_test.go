@@ -0,0 +1,130 @@
+package ppt
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/richardlehane/mscfb"
+	"github.com/richardlehane/mscfb/mscfbtest"
+)
+
+func TestSlides(t *testing.T) {
+	file, _ := os.Open("../test/test.ppt")
+	defer file.Close()
+	cfb, err := mscfb.New(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := New(cfb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Slides) != 2 || p.Slides[0].Text[0] != "Test" || !strings.Contains(p.Slides[0].Text[1], "Lorem ipsum") ||
+		!reflect.DeepEqual(p.Slides[1].Text, []string{"Test 2"}) {
+		t.Errorf("bad slides %q", p.Slides)
+	}
+	if pics, err := p.Pictures(); len(pics) != 0 || err != nil {
+		t.Errorf("expecting no pictures, got %v %v", pics, err)
+	}
+}
+
+func le(v ...interface{}) []byte {
+	buf := &bytes.Buffer{}
+	for _, x := range v {
+		binary.Write(buf, binary.LittleEndian, x)
+	}
+	return buf.Bytes()
+}
+
+func rec(ver uint8, instance, typ uint16, data ...[]byte) []byte {
+	d := bytes.Join(data, nil)
+	return append(le(uint16(ver)|instance<<4, typ, uint32(len(d))), d...)
+}
+
+func chars(s string) []byte {
+	return le(utf16.Encode([]rune(s)))
+}
+
+func TestPresentation(t *testing.T) {
+	document := rec(0xF, 0, RecDocument,
+		rec(0xF, 0, RecSlideListWithText,
+			rec(0, 0, RecSlidePersistAtom, le(uint32(2), uint32(0), uint32(1), uint32(256), uint32(0))),
+			rec(0, 0, RecTextHeaderAtom, le(uint32(0))),
+			rec(0, 0, RecTextBytesAtom, []byte("Caf\xe9\rmenu"))),
+		rec(0xF, 2, RecSlideListWithText,
+			rec(0, 0, RecSlidePersistAtom, le(uint32(3), uint32(0), uint32(0), uint32(300), uint32(0)))))
+	slide := func(s string) []byte {
+		return rec(0xF, 0, RecSlide,
+			rec(2, 0, RecSlideAtom, make([]byte, 16), le(uint32(300), uint32(0))),
+			rec(0xF, 0, 0xF002, rec(0xF, 0, 0xF00D, rec(0, 0, RecTextCharsAtom, chars(s)))))
+	}
+	notes := rec(0xF, 0, RecNotes, rec(0xF, 0, 0xF002, rec(0xF, 0, 0xF00D, rec(0, 0, RecTextCharsAtom, chars("Speaker ✓")))))
+	var s []byte
+	add := func(b []byte) uint32 {
+		off := uint32(len(s))
+		s = append(s, b...)
+		return off
+	}
+	docOff, oldOff, notesOff := add(document), add(slide("old")), add(notes)
+	dirA := add(rec(0, 0, RecPersistDirectoryAtom, le(uint32(1|3<<20), docOff, oldOff, notesOff)))
+	editA := add(rec(0, 0, RecUserEditAtom, le(uint32(0), uint16(0), uint16(0x0300), uint32(0), dirA, uint32(1), uint32(4), uint32(0))))
+	newOff := add(slide("new"))
+	dirB := add(rec(0, 0, RecPersistDirectoryAtom, le(uint32(2|1<<20), newOff)))
+	editB := add(rec(0, 0, RecUserEditAtom, le(uint32(0), uint16(0), uint16(0x0300), editA, dirB, uint32(1), uint32(4), uint32(0))))
+	cu := rec(0, 0, RecCurrentUserAtom, le(uint32(0x14), uint32(headerToken), editB, uint16(0), uint16(0x3F4), uint8(3), uint8(0), uint16(0)))
+
+	var z bytes.Buffer
+	zw := zlib.NewWriter(&z)
+	zw.Write([]byte("EMF data"))
+	zw.Close()
+	emfHdr := make([]byte, metafileHeaderSize)
+	pictures := append(rec(0, 0x6E1, 0xF01E, make([]byte, 33), []byte("\x89PNG")),
+		rec(0, 0x3D4, 0xF01A, make([]byte, 16), emfHdr, z.Bytes())...)
+
+	b := mscfbtest.New()
+	b.Stream("Current User", cu)
+	b.Stream("PowerPoint Document", s)
+	b.Stream("Pictures", pictures)
+	buf, err := b.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfb, err := mscfb.New(bytes.NewReader(buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := New(cfb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []Slide{{ID: 256, Text: []string{"Café\rmenu", "new"}, Notes: []string{"Speaker ✓"}}}
+	if !reflect.DeepEqual(p.Slides, expect) {
+		t.Errorf("expecting %q, got %q", expect, p.Slides)
+	}
+	pics, err := p.Pictures()
+	if err != nil || len(pics) != 2 {
+		t.Fatalf("expecting two pictures, got %v %v", pics, err)
+	}
+	for i, e := range []struct{ typ, data string }{{"png", "\x89PNG"}, {"emf", "EMF data"}} {
+		data, err := pics[i].Bytes()
+		if pics[i].Type != e.typ || string(data) != e.data || err != nil {
+			t.Errorf("bad picture %s %q %v", pics[i].Type, data, err)
+		}
+	}
+
+	binary.LittleEndian.PutUint32(cu[12:], encryptedHeaderToken)
+	b = mscfbtest.New()
+	b.Stream("Current User", cu)
+	b.Stream("PowerPoint Document", s)
+	buf, _ = b.Bytes()
+	cfb, _ = mscfb.New(bytes.NewReader(buf))
+	if _, err := New(cfb); err != ErrEncrypted {
+		t.Errorf("expecting ErrEncrypted, got %v", err)
+	}
+}
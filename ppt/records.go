@@ -0,0 +1,118 @@
+// Copyright 2013 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ppt
+
+import "encoding/binary"
+
+// Record types
+const (
+	RecDocument             uint16 = 0x03E8
+	RecSlide                uint16 = 0x03EE
+	RecSlideAtom            uint16 = 0x03EF
+	RecNotes                uint16 = 0x03F0
+	RecSlidePersistAtom     uint16 = 0x03F3
+	RecSlideListWithText    uint16 = 0x0FF0
+	RecUserEditAtom         uint16 = 0x0FF5
+	RecCurrentUserAtom      uint16 = 0x0FF6
+	RecTextHeaderAtom       uint16 = 0x0F9F
+	RecTextCharsAtom        uint16 = 0x0FA0
+	RecTextBytesAtom        uint16 = 0x0FA8
+	RecPersistDirectoryAtom uint16 = 0x1772
+)
+
+const headerSize = 8
+
+// RecordHeader is the header of a record
+type RecordHeader struct {
+	Version  uint8 // 0xF for containers
+	Instance uint16
+	Type     uint16
+	Length   uint32
+}
+
+func parseHeader(b []byte) RecordHeader {
+	vi := binary.LittleEndian.Uint16(b)
+	return RecordHeader{
+		Version:  uint8(vi & 0xF),
+		Instance: vi >> 4,
+		Type:     binary.LittleEndian.Uint16(b[2:]),
+		Length:   binary.LittleEndian.Uint32(b[4:]),
+	}
+}
+
+// Container reports whether the record contains other records
+func (h RecordHeader) Container() bool {
+	return h.Version == 0xF
+}
+
+// Record is a record with its children, if a container, or its data, if an atom
+type Record struct {
+	RecordHeader
+	Offset   int64 // offset of the record header in the stream
+	Data     []byte
+	Children []*Record
+}
+
+// ParseRecords parses a sequence of records, and the records within any containers. The offset of the data in the
+// stream is used to set record offsets.
+func ParseRecords(b []byte, offset int64) ([]*Record, error) {
+	return parseRecords(b, offset, 0)
+}
+
+const maxDepth = 64
+
+func parseRecords(b []byte, offset int64, depth int) ([]*Record, error) {
+	if depth > maxDepth {
+		return nil, ErrFormat
+	}
+	var ret []*Record
+	for len(b) >= headerSize {
+		r := &Record{RecordHeader: parseHeader(b), Offset: offset}
+		if int64(r.Length) > int64(len(b)-headerSize) {
+			return ret, ErrFormat
+		}
+		data := b[headerSize : headerSize+int(r.Length)]
+		if r.Container() {
+			var err error
+			if r.Children, err = parseRecords(data, offset+headerSize, depth+1); err != nil {
+				return ret, err
+			}
+		} else {
+			r.Data = data
+		}
+		ret = append(ret, r)
+		b = b[headerSize+int(r.Length):]
+		offset += headerSize + int64(r.Length)
+	}
+	return ret, nil
+}
+
+// Find returns the first child of a given type
+func (r *Record) Find(typ uint16) *Record {
+	for _, c := range r.Children {
+		if c.Type == typ {
+			return c
+		}
+	}
+	return nil
+}
+
+// Walk calls fn for the record and its descendants, depth first
+func (r *Record) Walk(fn func(*Record)) {
+	fn(r)
+	for _, c := range r.Children {
+		c.Walk(fn)
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2013 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mscfb
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// Options configure NewFromReader and NewWithOptions. Zero values select the defaults.
+type Options struct {
+	MemoryLimit int64  // compound files up to this size are held in memory; default 32 MiB
+	TempDir     string // directory for temporary files holding larger compound files; default os.TempDir()
+	Observer    Observer
+}
+
+const defaultMemoryLimit = 32 << 20
+
+// tempFile removes a temporary file when closed
+type tempFile struct {
+	io.Closer
+	name string
+}
+
+func (t tempFile) Close() error {
+	err := t.Closer.Close()
+	if rerr := os.Remove(t.name); err == nil {
+		err = rerr
+	}
+	return err
+}
+
+// NewFromReader returns a Reader for a compound file read from a non-seekable reader, such as a network
+// connection or pipe. The compound file is read to the end: it is held in memory if no larger than the memory limit,
+// otherwise it is spooled to a temporary file (memory mapped on Linux). The Reader must be closed with Close,
+// which removes any temporary file.
+func NewFromReader(rd io.Reader, opts Options) (*Reader, error) {
+	if opts.MemoryLimit <= 0 {
+		opts.MemoryLimit = defaultMemoryLimit
+	}
+	buf := &bytes.Buffer{}
+	if _, err := io.CopyN(buf, rd, opts.MemoryLimit+1); err == io.EOF {
+		return NewWithOptions(&mapping{b: buf.Bytes()}, opts)
+	} else if err != nil {
+		return nil, err
+	}
+	f, err := os.CreateTemp(opts.TempDir, "mscfb-*")
+	if err != nil {
+		return nil, err
+	}
+	closer := tempFile{f, f.Name()}
+	size, err := buf.WriteTo(f)
+	if err == nil {
+		var n int64
+		n, err = io.Copy(f, rd)
+		size += n
+	}
+	if err != nil {
+		closer.Close()
+		return nil, err
+	}
+	var ra io.ReaderAt = f
+	if b, err := mmap(f, size); err == nil {
+		m := &mapping{b: b, f: f}
+		ra, closer.Closer = m, m
+	}
+	r, err := NewWithOptions(ra, opts)
+	if err != nil {
+		closer.Close()
+		return nil, err
+	}
+	r.closer = closer
+	return r, nil
+}
@@ -0,0 +1,42 @@
+package mscfb
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+)
+
+func TestNewFromReader(t *testing.T) {
+	raw, _ := os.ReadFile(testXls)
+	expect := readAll(t, bytes.NewReader(raw))
+	dir := t.TempDir()
+	for _, limit := range []int64{0, 1000} {
+		doc, err := NewFromReader(io.MultiReader(bytes.NewReader(raw)), Options{MemoryLimit: limit, TempDir: dir})
+		if err != nil {
+			t.Fatal(err)
+		}
+		spooled, _ := os.ReadDir(dir)
+		if (limit > 0) != (len(spooled) == 1) {
+			t.Errorf("limit %d: expecting a temporary file only for large files, got %d", limit, len(spooled))
+		}
+		for i, f := range doc.File {
+			b, err := io.ReadAll(f)
+			if err != nil || !bytes.Equal(b, expect[i]) {
+				t.Errorf("limit %d: %s differs (%v)", limit, f.Name, err)
+			}
+		}
+		if err := doc.Close(); err != nil {
+			t.Error(err)
+		}
+		if spooled, _ = os.ReadDir(dir); len(spooled) != 0 {
+			t.Errorf("limit %d: temporary file not removed", limit)
+		}
+	}
+	if _, err := NewFromReader(bytes.NewReader(raw[:1000]), Options{MemoryLimit: 500, TempDir: dir}); err == nil {
+		t.Error("expecting an error for a truncated file")
+	}
+	if spooled, _ := os.ReadDir(dir); len(spooled) != 0 {
+		t.Error("temporary file not removed after error")
+	}
+}
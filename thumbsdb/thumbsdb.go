@@ -0,0 +1,185 @@
+// Copyright 2013 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package thumbsdb reads Windows Thumbs.db thumbnail caches.
+//
+// A Thumbs.db file has a Catalog stream listing the original filenames and modification times of the thumbnailed
+// files. Each thumbnail is held in a stream named with the reversed decimal ID of its catalog entry.
+//
+// Example:
+//
+//	doc, _ := mscfb.New(file)
+//	db, err := thumbsdb.New(doc)
+//	if err != nil {
+//	  log.Fatal(err)
+//	}
+//	thumbs, _ := db.Thumbnails()
+//	for _, t := range thumbs {
+//	  fmt.Println(t.Name, t.Modified)
+//	  io.Copy(out, t.Reader())
+//	}
+package thumbsdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/richardlehane/mscfb"
+	"github.com/richardlehane/mscfb/internal/ole"
+)
+
+// ErrFormat is returned for compound files without a Catalog stream, and for malformed catalogs and thumbnail streams
+var ErrFormat = errors.New("thumbsdb: bad Thumbs.db file")
+
+// ErrNoThumbnail is returned by Thumbnail for IDs without a thumbnail stream
+var ErrNoThumbnail = errors.New("thumbsdb: no such thumbnail")
+
+// Entry is a catalog entry
+type Entry struct {
+	ID       uint32
+	Name     string    // filename of the original file
+	Modified time.Time // modification time of the original file
+}
+
+// Thumbnail is a thumbnail image, usually a JPEG
+type Thumbnail struct {
+	Entry
+	Type   uint32 // type from the thumbnail stream header
+	Size   int64
+	file   *mscfb.File
+	offset int64
+}
+
+// Reader returns the image data
+func (t *Thumbnail) Reader() io.Reader {
+	return io.NewSectionReader(t.file, t.offset, t.Size)
+}
+
+// DB is a Thumbs.db thumbnail cache
+type DB struct {
+	Version int
+	Width   int // maximum thumbnail width
+	Height  int // maximum thumbnail height
+	Entries []Entry
+	files   map[string]*mscfb.File
+}
+
+// New reads the catalog of a Thumbs.db file
+func New(r *mscfb.Reader) (*DB, error) {
+	db := &DB{files: make(map[string]*mscfb.File)}
+	var catalog *mscfb.File
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() || len(f.Path) > 0 {
+			continue
+		}
+		if f.Name == "Catalog" {
+			catalog = f
+		} else {
+			db.files[f.Name] = f
+		}
+	}
+	if catalog == nil {
+		return nil, ErrFormat
+	}
+	buf, err := ole.ReadAll(catalog)
+	if err != nil {
+		return nil, err
+	}
+	if len(buf) < 16 {
+		return nil, ErrFormat
+	}
+	hdrLen := int(binary.LittleEndian.Uint16(buf))
+	if hdrLen < 16 || hdrLen > len(buf) {
+		return nil, ErrFormat
+	}
+	db.Version = int(binary.LittleEndian.Uint16(buf[2:]))
+	count := binary.LittleEndian.Uint32(buf[4:])
+	db.Width = int(binary.LittleEndian.Uint32(buf[8:]))
+	db.Height = int(binary.LittleEndian.Uint32(buf[12:]))
+	// entries: length, ID, FILETIME and a null-terminated UTF-16 filename
+	for b := buf[hdrLen:]; count > 0 && len(b) >= 4; count-- {
+		l := int(binary.LittleEndian.Uint32(b))
+		if l < 16 || l > len(b) {
+			return nil, ErrFormat
+		}
+		db.Entries = append(db.Entries, Entry{
+			ID:       binary.LittleEndian.Uint32(b[4:]),
+			Modified: ole.Filetime(binary.LittleEndian.Uint64(b[8:])),
+			Name:     ole.UTF16Z(b[16:l]),
+		})
+		b = b[l:]
+	}
+	return db, nil
+}
+
+// StreamName returns the name of the stream holding the thumbnail for an ID: the ID in decimal, reversed
+func StreamName(id uint32) string {
+	s := []byte(strconv.FormatUint(uint64(id), 10))
+	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
+		s[i], s[j] = s[j], s[i]
+	}
+	return string(s)
+}
+
+// Thumbnail returns the thumbnail for a catalog entry
+func (db *DB) Thumbnail(id uint32) (*Thumbnail, error) {
+	f, ok := db.files[StreamName(id)]
+	if !ok {
+		return nil, ErrNoThumbnail
+	}
+	t := &Thumbnail{Entry: Entry{ID: id}, file: f}
+	for _, e := range db.Entries {
+		if e.ID == id {
+			t.Entry = e
+			break
+		}
+	}
+	// header: header length, type and image size
+	hdr := make([]byte, 12)
+	if n, err := f.ReadAt(hdr, 0); n < len(hdr) {
+		if err == nil || err == io.EOF {
+			err = ErrFormat
+		}
+		return nil, err
+	}
+	t.offset = int64(binary.LittleEndian.Uint32(hdr))
+	if t.offset < 12 || t.offset > f.Size {
+		return nil, ErrFormat
+	}
+	t.Type = binary.LittleEndian.Uint32(hdr[4:])
+	t.Size = int64(binary.LittleEndian.Uint32(hdr[8:]))
+	if t.Size > f.Size-t.offset {
+		t.Size = f.Size - t.offset
+	}
+	return t, nil
+}
+
+// Thumbnails returns the thumbnails for all catalog entries that have one
+func (db *DB) Thumbnails() ([]*Thumbnail, error) {
+	ret := make([]*Thumbnail, 0, len(db.Entries))
+	for _, e := range db.Entries {
+		t, err := db.Thumbnail(e.ID)
+		if err == ErrNoThumbnail {
+			continue
+		}
+		if err != nil {
+			return ret, err
+		}
+		ret = append(ret, t)
+	}
+	return ret, nil
+}
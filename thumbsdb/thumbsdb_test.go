@@ -0,0 +1,82 @@
+package thumbsdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	"github.com/richardlehane/mscfb"
+	"github.com/richardlehane/mscfb/mscfbtest"
+)
+
+func entry(id uint32, mod time.Time, name string) []byte {
+	u := utf16.Encode([]rune(name + "\x00"))
+	b := make([]byte, 16, 16+len(u)*2+2)
+	binary.LittleEndian.PutUint32(b[4:], id)
+	binary.LittleEndian.PutUint64(b[8:], uint64(mod.UnixNano()/100+116444736000000000))
+	for _, c := range u {
+		b = append(b, byte(c), byte(c>>8))
+	}
+	b = append(b, 0, 0)
+	binary.LittleEndian.PutUint32(b, uint32(len(b)))
+	return b
+}
+
+func thumb(img []byte) []byte {
+	b := make([]byte, 12, 12+len(img))
+	binary.LittleEndian.PutUint32(b, 12)
+	binary.LittleEndian.PutUint32(b[4:], 2)
+	binary.LittleEndian.PutUint32(b[8:], uint32(len(img)))
+	return append(b, img...)
+}
+
+func TestThumbsDB(t *testing.T) {
+	mod := time.Date(2005, 3, 1, 10, 30, 0, 0, time.UTC)
+	catalog := []byte{16, 0, 5, 0, 3, 0, 0, 0, 96, 0, 0, 0, 96, 0, 0, 0}
+	catalog = append(catalog, entry(1, mod, "holiday.jpg")...)
+	catalog = append(catalog, entry(12, mod.Add(time.Hour), "café.png")...)
+	catalog = append(catalog, entry(13, mod, "deleted.bmp")...)
+	jpeg := append([]byte{0xFF, 0xD8, 0xFF, 0xE0}, bytes.Repeat([]byte{0x55}, 5000)...)
+	b := mscfbtest.New()
+	b.Stream("Catalog", catalog)
+	b.Stream("1", thumb([]byte{0xFF, 0xD8, 0xFF, 0xD9}))
+	b.Stream("21", thumb(jpeg))
+	buf, err := b.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := mscfb.New(bytes.NewReader(buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := New(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db.Version != 5 || db.Width != 96 || len(db.Entries) != 3 {
+		t.Fatalf("bad catalog %+v", db)
+	}
+	if e := db.Entries[1]; e.ID != 12 || e.Name != "café.png" || !e.Modified.Equal(mod.Add(time.Hour)) {
+		t.Errorf("bad entry %+v", e)
+	}
+	thumbs, err := db.Thumbnails()
+	if err != nil || len(thumbs) != 2 {
+		t.Fatalf("expecting two thumbnails, got %v %v", thumbs, err)
+	}
+	img, _ := io.ReadAll(thumbs[1].Reader())
+	if thumbs[1].Name != "café.png" || thumbs[1].Type != 2 || !bytes.Equal(img, jpeg) {
+		t.Errorf("bad thumbnail %q %d %d", thumbs[1].Name, thumbs[1].Type, len(img))
+	}
+	if _, err := db.Thumbnail(13); err != ErrNoThumbnail {
+		t.Errorf("expecting ErrNoThumbnail, got %v", err)
+	}
+}
+
+func TestStreamName(t *testing.T) {
+	if StreamName(1234) != "4321" || StreamName(7) != "7" {
+		t.Errorf("bad stream names %s %s", StreamName(1234), StreamName(7))
+	}
+}
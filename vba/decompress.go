@@ -0,0 +1,101 @@
+// Copyright 2013 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vba
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+const chunkSize = 4096
+
+// ErrCompression is returned for malformed compressed containers
+var ErrCompression = errors.New("vba: bad compressed container")
+
+// Decompress expands a CompressedContainer using the MS-OVBA RLE algorithm (MS-OVBA 2.4.1)
+func Decompress(b []byte) ([]byte, error) {
+	if len(b) < 1 || b[0] != 0x01 {
+		return nil, ErrCompression
+	}
+	out := make([]byte, 0, len(b)*2)
+	for pos := 1; pos < len(b); {
+		if pos+2 > len(b) {
+			return nil, ErrCompression
+		}
+		hdr := binary.LittleEndian.Uint16(b[pos:])
+		if (hdr>>12)&0x7 != 0x3 {
+			return nil, ErrCompression
+		}
+		end := pos + int(hdr&0x0FFF) + 3
+		if end > len(b) {
+			end = len(b)
+		}
+		pos += 2
+		if hdr&0x8000 == 0 {
+			// uncompressed chunk: always 4096 bytes
+			if pos+chunkSize > len(b) {
+				return nil, ErrCompression
+			}
+			out = append(out, b[pos:pos+chunkSize]...)
+			pos += chunkSize
+			continue
+		}
+		start := len(out)
+		for pos < end {
+			flags := b[pos]
+			pos++
+			for i := 0; i < 8 && pos < end; i++ {
+				if flags&(1<<i) == 0 {
+					if len(out)-start >= chunkSize {
+						return nil, ErrCompression
+					}
+					out = append(out, b[pos])
+					pos++
+					continue
+				}
+				if pos+2 > end {
+					return nil, ErrCompression
+				}
+				token := binary.LittleEndian.Uint16(b[pos:])
+				pos += 2
+				offset, length := unpack(token, len(out)-start)
+				// a chunk decompresses to no more than 4096 bytes
+				if offset > len(out)-start || len(out)-start+length > chunkSize {
+					return nil, ErrCompression
+				}
+				// copies may overlap their source so go byte by byte
+				src := len(out) - offset
+				for j := 0; j < length; j++ {
+					out = append(out, out[src+j])
+				}
+			}
+		}
+		pos = end
+	}
+	return out, nil
+}
+
+// unpack a CopyToken given the number of bytes decompressed so far in the current chunk
+func unpack(token uint16, decompressed int) (offset, length int) {
+	bits := 4
+	for 1<<bits < decompressed {
+		bits++
+	}
+	if bits > 12 {
+		bits = 12
+	}
+	mask := uint16(0xFFFF) >> bits
+	return int(token>>(16-bits)) + 1, int(token&mask) + 3
+}
@@ -0,0 +1,207 @@
+// Copyright 2013 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package vba extracts VBA macro source code from compound files (https://learn.microsoft.com/en-us/openspecs/office_file_formats/ms-ovba).
+//
+// VBA projects are found wherever a VBA storage with a dir stream appears in the tree: e.g. Macros/VBA in Word documents
+// and _VBA_PROJECT_CUR/VBA in Excel workbooks.
+//
+// Example:
+//
+//	doc, _ := mscfb.New(file)
+//	projects, err := vba.Find(doc)
+//	if err != nil {
+//	  log.Fatal(err)
+//	}
+//	for _, p := range projects {
+//	  for _, m := range p.Modules {
+//	    fmt.Println(m.Name, m.Type)
+//	    fmt.Println(m.Source)
+//	  }
+//	}
+package vba
+
+import (
+	"encoding/binary"
+	"errors"
+	"strings"
+
+	"github.com/richardlehane/mscfb"
+	"github.com/richardlehane/mscfb/internal/ole"
+)
+
+// ModuleType distinguishes procedural modules from document, class and designer modules
+type ModuleType int
+
+const (
+	Procedural ModuleType = iota // standard module
+	Class                        // document, class or designer module
+)
+
+func (t ModuleType) String() string {
+	if t == Class {
+		return "class"
+	}
+	return "procedural"
+}
+
+// Project is a VBA project
+type Project struct {
+	Path     []string // path to the VBA storage
+	Name     string
+	CodePage uint16
+	Modules  []*Module
+}
+
+// Module is a VBA module and its decompressed source
+type Module struct {
+	Name       string
+	StreamName string // name of the module stream within the VBA storage
+	Type       ModuleType
+	ReadOnly   bool
+	Private    bool
+	Offset     uint32 // offset of the compressed source within the module stream
+	Source     string
+}
+
+// ErrFormat is returned for malformed dir streams
+var ErrFormat = errors.New("vba: bad dir stream")
+
+// Find returns the VBA projects in a compound file
+func Find(r *mscfb.Reader) ([]*Project, error) {
+	streams := make(map[string]*mscfb.File)
+	for _, f := range r.File {
+		streams[strings.Join(append(append([]string{}, f.Path...), f.Name), "/")] = f
+	}
+	var ret []*Project
+	for _, f := range r.File {
+		if f.Name != "VBA" || !f.FileInfo().IsDir() {
+			continue
+		}
+		path := append(append([]string{}, f.Path...), f.Name)
+		prefix := strings.Join(path, "/") + "/"
+		dir, ok := streams[prefix+"dir"]
+		if !ok {
+			continue
+		}
+		p, err := parseProject(dir)
+		if err != nil {
+			return ret, err
+		}
+		p.Path = path
+		for _, m := range p.Modules {
+			ms, ok := streams[prefix+m.StreamName]
+			if !ok {
+				return ret, errors.New("vba: missing module stream " + m.StreamName)
+			}
+			buf, err := ole.ReadAll(ms)
+			if err != nil {
+				return ret, err
+			}
+			if int(m.Offset) > len(buf) {
+				return ret, errors.New("vba: module offset beyond stream " + m.StreamName)
+			}
+			src, err := Decompress(buf[m.Offset:])
+			if err != nil {
+				return ret, err
+			}
+			m.Source = ole.Decode(src, int(p.CodePage))
+		}
+		ret = append(ret, p)
+	}
+	return ret, nil
+}
+
+// dir stream record IDs (MS-OVBA 2.3.4.2)
+const (
+	idCodePage          uint16 = 0x0003
+	idProjectName       uint16 = 0x0004
+	idProjectVersion    uint16 = 0x0009
+	idModuleName        uint16 = 0x0019
+	idModuleNameUnicode uint16 = 0x0047
+	idModuleStreamName  uint16 = 0x001A
+	idModuleOffset      uint16 = 0x0031
+	idModuleProcedural  uint16 = 0x0021
+	idModuleClass       uint16 = 0x0022
+	idModuleReadOnly    uint16 = 0x0025
+	idModulePrivate     uint16 = 0x0028
+	idModuleTerminator  uint16 = 0x002B
+	idTerminator        uint16 = 0x0010
+	idStreamNameUnicode uint16 = 0x0032
+)
+
+func parseProject(dir *mscfb.File) (*Project, error) {
+	raw, err := ole.ReadAll(dir)
+	if err != nil {
+		return nil, err
+	}
+	b, err := Decompress(raw)
+	if err != nil {
+		return nil, err
+	}
+	p := &Project{CodePage: 1252}
+	var m *Module
+	for pos := 0; pos+6 <= len(b); {
+		id, sz := binary.LittleEndian.Uint16(b[pos:]), int(binary.LittleEndian.Uint32(b[pos+2:]))
+		pos += 6
+		if id == idProjectVersion {
+			// the size field is always 4 but the record holds 6 bytes of version
+			sz = 6
+		}
+		if sz < 0 || pos+sz > len(b) {
+			return nil, ErrFormat
+		}
+		data := b[pos : pos+sz]
+		pos += sz
+		switch id {
+		case idCodePage:
+			if sz >= 2 {
+				p.CodePage = binary.LittleEndian.Uint16(data)
+			}
+		case idProjectName:
+			p.Name = ole.Decode(data, int(p.CodePage))
+		case idModuleName:
+			m = &Module{Name: ole.Decode(data, int(p.CodePage))}
+			p.Modules = append(p.Modules, m)
+		}
+		if m == nil {
+			if id == idTerminator {
+				break
+			}
+			continue
+		}
+		switch id {
+		case idModuleNameUnicode:
+			m.Name = ole.UTF16(data)
+		case idModuleStreamName:
+			m.StreamName = ole.Decode(data, int(p.CodePage))
+		case idStreamNameUnicode:
+			m.StreamName = ole.UTF16(data)
+		case idModuleOffset:
+			if sz < 4 {
+				return nil, ErrFormat
+			}
+			m.Offset = binary.LittleEndian.Uint32(data)
+		case idModuleClass:
+			m.Type = Class
+		case idModuleReadOnly:
+			m.ReadOnly = true
+		case idModulePrivate:
+			m.Private = true
+		case idModuleTerminator:
+			m = nil
+		}
+	}
+	return p, nil
+}
@@ -0,0 +1,120 @@
+package vba
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/richardlehane/mscfb"
+	"github.com/richardlehane/mscfb/internal/oletest"
+	"github.com/richardlehane/mscfb/mscfbtest"
+)
+
+func TestDecompress(t *testing.T) {
+	// examples from MS-OVBA 3.2
+	for _, v := range []struct {
+		compressed []byte
+		expect     string
+	}{
+		{
+			[]byte{0x01, 0x19, 0xB0, 0x00, 0x61, 0x62, 0x63, 0x64, 0x65, 0x66, 0x67, 0x68, 0x00, 0x69, 0x6A, 0x6B, 0x6C,
+				0x6D, 0x6E, 0x6F, 0x70, 0x00, 0x71, 0x72, 0x73, 0x74, 0x75, 0x76, 0x2E},
+			"abcdefghijklmnopqrstuv.",
+		},
+		{
+			[]byte{0x01, 0x2F, 0xB0, 0x00, 0x23, 0x61, 0x61, 0x61, 0x62, 0x63, 0x64, 0x65, 0x82, 0x66, 0x00, 0x70, 0x61,
+				0x67, 0x68, 0x69, 0x6A, 0x01, 0x38, 0x08, 0x61, 0x6B, 0x6C, 0x00, 0x30, 0x6D, 0x6E, 0x6F, 0x70, 0x06, 0x71,
+				0x02, 0x70, 0x04, 0x10, 0x72, 0x73, 0x74, 0x75, 0x76, 0x10, 0x77, 0x78, 0x79, 0x7A, 0x00, 0x3C},
+			"#aaabcdefaaaaghijaaaaaklaaamnopqaaaaaaaaaaaarstuvwxyzaaa",
+		},
+		{
+			[]byte{0x01, 0x03, 0xB0, 0x02, 0x61, 0x45, 0x00},
+			"aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
+		},
+	} {
+		got, err := Decompress(v.compressed)
+		if err != nil || string(got) != v.expect {
+			t.Errorf("expecting %q, got %q (%v)", v.expect, got, err)
+		}
+	}
+	long := bytes.Repeat([]byte("Sub AutoOpen()\r\n"), 600)
+	if got, err := Decompress(oletest.Compress(long)); err != nil || !bytes.Equal(got, long) {
+		t.Errorf("multiple chunk decompression failed: %v", err)
+	}
+	// a copy token that takes the chunk past 4096 bytes
+	if _, err := Decompress([]byte{0x01, 0x03, 0xB0, 0x02, 0x61, 0xFF, 0x0F}); err != ErrCompression {
+		t.Errorf("expecting a compression error for an oversized chunk, got %v", err)
+	}
+}
+
+func TestFind(t *testing.T) {
+	var dir []byte
+	for _, r := range [][]byte{
+		oletest.Record(0x0001, oletest.U32(1)),
+		oletest.Record(idCodePage, oletest.U16(1252)),
+		oletest.Record(idProjectName, []byte("VBAProject")),
+		append(oletest.Record(idProjectVersion, oletest.U32(0x4E))[:6], append(oletest.U32(1), oletest.U16(2)...)...),
+		oletest.Record(0x000F, oletest.U16(2)),
+		oletest.Record(0x0013, oletest.U16(0xFFFF)),
+		oletest.Record(idModuleName, []byte("ThisDocument")),
+		oletest.Record(idModuleStreamName, []byte("ThisDocument")),
+		oletest.Record(idStreamNameUnicode, []byte{'T', 0, 'h', 0, 'i', 0, 's', 0, 'D', 0, 'o', 0, 'c', 0, 'u', 0, 'm', 0, 'e', 0, 'n', 0, 't', 0}),
+		oletest.Record(idModuleOffset, oletest.U32(10)),
+		oletest.Record(idModuleClass, nil),
+		oletest.Record(idModuleTerminator, nil),
+		oletest.Record(idModuleName, []byte("Module1")),
+		oletest.Record(idModuleStreamName, []byte("Module1")),
+		oletest.Record(idModuleOffset, oletest.U32(0)),
+		oletest.Record(idModuleProcedural, nil),
+		oletest.Record(idModuleTerminator, nil),
+		oletest.Record(idTerminator, nil),
+	} {
+		dir = append(dir, r...)
+	}
+	doc := "Attribute VB_Name = \"ThisDocument\"\r\nSub Document_Open()\r\nEnd Sub\r\n"
+	mod := "Attribute VB_Name = \"Module1\"\r\nSub AutoOpen()\r\n  MsgBox \"caf\xe9\"\r\nEnd Sub\r\n"
+	b := mscfbtest.New()
+	b.Stream("WordDocument", make([]byte, 100))
+	b.Storage("Macros").Storage("VBA").
+		Stream("dir", oletest.Compress(dir)).
+		Stream("ThisDocument", append(make([]byte, 10), oletest.Compress([]byte(doc))...)).
+		Stream("Module1", oletest.Compress([]byte(mod))).
+		Stream("_VBA_PROJECT", []byte{0xCC, 0x61, 0xFF, 0xFF})
+	buf, err := b.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := mscfb.New(bytes.NewReader(buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	projects, err := Find(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(projects) != 1 || len(projects[0].Modules) != 2 {
+		t.Fatalf("expecting one project with two modules, got %v", projects)
+	}
+	p := projects[0]
+	if p.Name != "VBAProject" || len(p.Path) != 2 || p.Path[0] != "Macros" {
+		t.Errorf("bad project %s %v", p.Name, p.Path)
+	}
+	if m := p.Modules[0]; m.Name != "ThisDocument" || m.Type != Class || m.Source != doc {
+		t.Errorf("bad module %s %s %q", m.Name, m.Type, m.Source)
+	}
+	if m := p.Modules[1]; m.Name != "Module1" || m.Type != Procedural || m.Source != "Attribute VB_Name = \"Module1\"\r\nSub AutoOpen()\r\n  MsgBox \"café\"\r\nEnd Sub\r\n" {
+		t.Errorf("bad module %s %s %q", m.Name, m.Type, m.Source)
+	}
+}
+
+func TestNoMacros(t *testing.T) {
+	file, _ := os.Open("../test/test.doc")
+	defer file.Close()
+	r, err := mscfb.New(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, err := Find(r); len(p) != 0 || err != nil {
+		t.Errorf("expecting no projects, got %d %v", len(p), err)
+	}
+}
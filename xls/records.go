@@ -0,0 +1,122 @@
+// Copyright 2013 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xls
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// Record types
+const (
+	RecFormula    uint16 = 0x0006
+	RecEOF        uint16 = 0x000A
+	RecFilePass   uint16 = 0x002F
+	RecDateMode   uint16 = 0x0022
+	RecContinue   uint16 = 0x003C
+	RecCodePage   uint16 = 0x0042
+	RecBoundSheet uint16 = 0x0085
+	RecMulRK      uint16 = 0x00BD
+	RecRString    uint16 = 0x00D6
+	RecSST        uint16 = 0x00FC
+	RecLabelSST   uint16 = 0x00FD
+	RecNumber     uint16 = 0x0203
+	RecLabel      uint16 = 0x0204
+	RecBoolErr    uint16 = 0x0205
+	RecString     uint16 = 0x0207
+	RecRK         uint16 = 0x027E
+	RecBOF        uint16 = 0x0809
+)
+
+// Record is a BIFF record. The payloads of any CONTINUE records that follow it are appended to Data.
+type Record struct {
+	Type      uint16
+	Offset    int64 // offset of the record header in the stream
+	Data      []byte
+	Continues []int // offsets in Data at which the payloads of CONTINUE records begin
+}
+
+// Reader iterates the records in a BIFF5 or BIFF8 stream
+type Reader struct {
+	ra   io.ReaderAt
+	size int64
+	off  int64
+}
+
+// NewReader returns a Reader for a Workbook or Book stream
+func NewReader(ra io.ReaderAt, size int64) *Reader {
+	return &Reader{ra: ra, size: size}
+}
+
+// Next returns the next record, or io.EOF at the end of the stream
+func (r *Reader) Next() (*Record, error) {
+	typ, l, err := r.header()
+	if err != nil {
+		return nil, err
+	}
+	rec := &Record{Type: typ, Offset: r.off - 4}
+	if rec.Data, err = r.payload(l); err != nil {
+		return nil, err
+	}
+	for {
+		typ, l, err := r.header()
+		if err == io.EOF {
+			return rec, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if typ != RecContinue {
+			r.off -= 4
+			return rec, nil
+		}
+		b, err := r.payload(l)
+		if err != nil {
+			return nil, err
+		}
+		rec.Continues = append(rec.Continues, len(rec.Data))
+		rec.Data = append(rec.Data, b...)
+	}
+}
+
+func (r *Reader) header() (uint16, int, error) {
+	if r.off+4 > r.size {
+		return 0, 0, io.EOF
+	}
+	var hdr [4]byte
+	if _, err := r.ra.ReadAt(hdr[:], r.off); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, 0, err
+	}
+	r.off += 4
+	return binary.LittleEndian.Uint16(hdr[:]), int(binary.LittleEndian.Uint16(hdr[2:])), nil
+}
+
+func (r *Reader) payload(l int) ([]byte, error) {
+	if r.off+int64(l) > r.size {
+		return nil, io.ErrUnexpectedEOF
+	}
+	b := make([]byte, l)
+	if l == 0 {
+		return b, nil
+	}
+	if _, err := r.ra.ReadAt(b, r.off); err != nil && err != io.EOF {
+		return nil, err
+	}
+	r.off += int64(l)
+	return b, nil
+}
@@ -0,0 +1,191 @@
+// Copyright 2013 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xls
+
+import (
+	"encoding/binary"
+	"strings"
+
+	"github.com/richardlehane/mscfb/internal/ole"
+)
+
+// String option flags
+const (
+	fHighByte = 0x01
+	fExtSt    = 0x04
+	fRichSt   = 0x08
+)
+
+// cursor reads across the CONTINUE boundaries of a record. Character data split by a boundary is resumed with a new
+// option flags byte at the start of the CONTINUE payload.
+type cursor struct {
+	data   []byte
+	bounds []int
+	pos    int
+}
+
+func newCursor(rec *Record, pos int) *cursor {
+	return &cursor{data: rec.Data, bounds: rec.Continues, pos: pos}
+}
+
+// next returns the offset of the next boundary after pos, or the length of the data
+func (c *cursor) next() int {
+	for _, b := range c.bounds {
+		if b > c.pos {
+			return b
+		}
+	}
+	return len(c.data)
+}
+
+// boundary reports whether pos is at the start of a CONTINUE payload
+func (c *cursor) boundary() bool {
+	for _, b := range c.bounds {
+		if b == c.pos {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *cursor) bytes(n int) ([]byte, bool) {
+	if n < 0 || c.pos+n > len(c.data) {
+		return nil, false
+	}
+	b := c.data[c.pos : c.pos+n]
+	c.pos += n
+	return b, true
+}
+
+func (c *cursor) u8() (byte, bool) {
+	b, ok := c.bytes(1)
+	if !ok {
+		return 0, false
+	}
+	return b[0], true
+}
+
+func (c *cursor) u16() (uint16, bool) {
+	b, ok := c.bytes(2)
+	if !ok {
+		return 0, false
+	}
+	return binary.LittleEndian.Uint16(b), true
+}
+
+func (c *cursor) u32() (uint32, bool) {
+	b, ok := c.bytes(4)
+	if !ok {
+		return 0, false
+	}
+	return binary.LittleEndian.Uint32(b), true
+}
+
+// chars reads n characters, compressed (one byte) or not, resuming after boundaries
+func (c *cursor) chars(n int, high bool) (string, bool) {
+	var sb strings.Builder
+	for n > 0 {
+		sz := 1
+		if high {
+			sz = 2
+		}
+		avail := (c.next() - c.pos) / sz
+		if c.boundary() || avail == 0 {
+			flags, ok := c.u8()
+			if !ok {
+				return sb.String(), false
+			}
+			high = flags&fHighByte != 0
+			continue
+		}
+		if avail > n {
+			avail = n
+		}
+		b, _ := c.bytes(avail * sz)
+		if high {
+			sb.WriteString(ole.UTF16(b))
+		} else {
+			sb.WriteString(ole.Latin1(b)) // compressed characters are the low bytes of UTF-16 code units
+		}
+		n -= avail
+	}
+	return sb.String(), true
+}
+
+// unicode reads an XLUnicodeRichExtendedString, or an XLUnicodeString if the rich and extended flags are clear.
+// The count is 16 bits, or 8 bits for a ShortXLUnicodeString.
+func (c *cursor) unicode(short bool) (string, bool) {
+	var n int
+	if short {
+		b, ok := c.u8()
+		if !ok {
+			return "", false
+		}
+		n = int(b)
+	} else {
+		l, ok := c.u16()
+		if !ok {
+			return "", false
+		}
+		n = int(l)
+	}
+	flags, ok := c.u8()
+	if !ok {
+		return "", false
+	}
+	var runs, ext int
+	if flags&fRichSt != 0 {
+		r, ok := c.u16()
+		if !ok {
+			return "", false
+		}
+		runs = int(r)
+	}
+	if flags&fExtSt != 0 {
+		e, ok := c.u32()
+		if !ok {
+			return "", false
+		}
+		ext = int(int32(e))
+	}
+	s, ok := c.chars(n, flags&fHighByte != 0)
+	if !ok {
+		return s, false
+	}
+	// formatting runs and phonetic data are skipped
+	_, ok = c.bytes(runs*4 + ext)
+	return s, ok
+}
+
+// sst decodes the shared string table
+func sst(rec *Record) []string {
+	c := newCursor(rec, 8)
+	if len(rec.Data) < 8 {
+		return nil
+	}
+	n := int(binary.LittleEndian.Uint32(rec.Data[4:]))
+	if n > len(rec.Data) {
+		n = len(rec.Data) // each string takes at least three bytes
+	}
+	ret := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		s, ok := c.unicode(false)
+		if !ok {
+			break
+		}
+		ret = append(ret, s)
+	}
+	return ret
+}
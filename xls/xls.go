@@ -0,0 +1,345 @@
+// Copyright 2013 Richard Lehane. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package xls reads the BIFF5 and BIFF8 records of Excel workbooks and extracts sheets and cells.
+//
+// Example:
+//
+//	doc, _ := mscfb.New(file)
+//	wb, err := xls.New(doc)
+//	if err != nil {
+//	  log.Fatal(err)
+//	}
+//	for i, sheet := range wb.Sheets {
+//	  cells, _ := wb.Cells(i)
+//	  for _, c := range cells {
+//	    fmt.Println(sheet.Name, c.Row, c.Col, c)
+//	  }
+//	}
+package xls
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"strconv"
+
+	"github.com/richardlehane/mscfb"
+	"github.com/richardlehane/mscfb/internal/ole"
+)
+
+var (
+	// ErrFormat is returned for compound files without a Workbook or Book stream, and for malformed workbooks
+	ErrFormat = errors.New("xls: bad workbook")
+	// ErrEncrypted is returned for workbooks protected with a FILEPASS record
+	ErrEncrypted = errors.New("xls: workbook is encrypted")
+)
+
+// BIFF versions
+const (
+	BIFF5 = 0x0500
+	BIFF8 = 0x0600
+)
+
+// Sheet types
+const (
+	Worksheet  = 0
+	MacroSheet = 1
+	Chart      = 2
+	VBModule   = 6
+)
+
+// Sheet is a sheet listed in a BOUNDSHEET record
+type Sheet struct {
+	Name   string
+	Type   int
+	Hidden int // 0 visible, 1 hidden, 2 very hidden
+	offset int64
+}
+
+// ErrorValue is the value of a cell holding an error
+type ErrorValue byte
+
+func (e ErrorValue) String() string {
+	switch e {
+	case 0x00:
+		return "#NULL!"
+	case 0x07:
+		return "#DIV/0!"
+	case 0x0F:
+		return "#VALUE!"
+	case 0x17:
+		return "#REF!"
+	case 0x1D:
+		return "#NAME?"
+	case 0x24:
+		return "#NUM!"
+	case 0x2A:
+		return "#N/A"
+	}
+	return "#ERR!"
+}
+
+// Cell is a cell with a value. Values are string, float64, bool or ErrorValue. The values of formula cells are
+// their cached results.
+type Cell struct {
+	Row   int
+	Col   int
+	Value interface{}
+}
+
+func (c Cell) String() string {
+	switch v := c.Value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		if v {
+			return "TRUE"
+		}
+		return "FALSE"
+	case ErrorValue:
+		return v.String()
+	}
+	return ""
+}
+
+// Workbook is an Excel workbook
+type Workbook struct {
+	Version  int // BIFF5 or BIFF8
+	CodePage int
+	Date1904 bool // dates are days since 1904 rather than 1900
+	Sheets   []Sheet
+	SST      []string // shared strings (BIFF8)
+	file     *mscfb.File
+}
+
+// New reads the globals substream of the Workbook (BIFF8) or Book (BIFF5) stream
+func New(r *mscfb.Reader) (*Workbook, error) {
+	wb := &Workbook{CodePage: 1252}
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() || len(f.Path) > 0 {
+			continue
+		}
+		if f.Name == "Workbook" || (f.Name == "Book" && wb.file == nil) {
+			wb.file = f
+		}
+	}
+	if wb.file == nil {
+		return nil, ErrFormat
+	}
+	rr := NewReader(wb.file, wb.file.Size)
+	bof, err := rr.Next()
+	if err != nil || bof.Type != RecBOF || len(bof.Data) < 4 {
+		return nil, ErrFormat
+	}
+	wb.Version = int(binary.LittleEndian.Uint16(bof.Data))
+	if wb.Version != BIFF5 && wb.Version != BIFF8 {
+		return nil, ErrFormat
+	}
+	for {
+		rec, err := rr.Next()
+		if err == io.EOF || (err == nil && rec.Type == RecEOF) {
+			return wb, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch rec.Type {
+		case RecFilePass:
+			return nil, ErrEncrypted
+		case RecCodePage:
+			if len(rec.Data) >= 2 {
+				wb.CodePage = int(binary.LittleEndian.Uint16(rec.Data))
+			}
+		case RecDateMode:
+			wb.Date1904 = len(rec.Data) >= 2 && rec.Data[0] == 1
+		case RecBoundSheet:
+			if len(rec.Data) < 8 {
+				return nil, ErrFormat
+			}
+			s := Sheet{
+				offset: int64(binary.LittleEndian.Uint32(rec.Data)),
+				Hidden: int(rec.Data[4] & 3),
+				Type:   int(rec.Data[5]),
+			}
+			if wb.Version == BIFF8 {
+				s.Name, _ = newCursor(rec, 6).unicode(true)
+			} else if l := int(rec.Data[6]); 7+l <= len(rec.Data) {
+				s.Name = ole.Decode(rec.Data[7:7+l], wb.CodePage)
+			}
+			wb.Sheets = append(wb.Sheets, s)
+		case RecSST:
+			wb.SST = sst(rec)
+		}
+	}
+}
+
+// Cells returns the cells of a sheet, in stream order
+func (wb *Workbook) Cells(sheet int) ([]Cell, error) {
+	if sheet < 0 || sheet >= len(wb.Sheets) {
+		return nil, ErrFormat
+	}
+	rr := NewReader(wb.file, wb.file.Size)
+	rr.off = wb.Sheets[sheet].offset
+	bof, err := rr.Next()
+	if err != nil || bof.Type != RecBOF {
+		return nil, ErrFormat
+	}
+	var (
+		cells   []Cell
+		formula = -1 // index of a formula cell awaiting a STRING record
+	)
+	for {
+		rec, err := rr.Next()
+		if err == io.EOF || (err == nil && rec.Type == RecEOF) {
+			return cells, nil
+		}
+		if err != nil {
+			return cells, err
+		}
+		d := rec.Data
+		if rec.Type == RecBOF {
+			// skip embedded substreams, such as charts
+			if err := skip(rr); err != nil {
+				return cells, err
+			}
+			continue
+		}
+		if len(d) < 6 && rec.Type != RecString {
+			continue
+		}
+		var cell Cell
+		if len(d) >= 4 {
+			cell = Cell{Row: int(binary.LittleEndian.Uint16(d)), Col: int(binary.LittleEndian.Uint16(d[2:]))}
+		}
+		switch rec.Type {
+		case RecLabelSST:
+			if len(d) < 10 {
+				continue
+			}
+			if idx := int(binary.LittleEndian.Uint32(d[6:])); idx < len(wb.SST) {
+				cell.Value = wb.SST[idx]
+			}
+		case RecLabel, RecRString:
+			s, ok := wb.str(rec, 6)
+			if !ok {
+				continue
+			}
+			cell.Value = s
+		case RecNumber:
+			if len(d) < 14 {
+				continue
+			}
+			cell.Value = math.Float64frombits(binary.LittleEndian.Uint64(d[6:]))
+		case RecRK:
+			if len(d) < 10 {
+				continue
+			}
+			cell.Value = rk(binary.LittleEndian.Uint32(d[6:]))
+		case RecMulRK:
+			for i := 4; i+6 <= len(d)-2; i += 6 {
+				cells = append(cells, Cell{Row: cell.Row, Col: cell.Col + (i-4)/6, Value: rk(binary.LittleEndian.Uint32(d[i+2:]))})
+			}
+			continue
+		case RecBoolErr:
+			if len(d) < 8 {
+				continue
+			}
+			if d[7] == 1 {
+				cell.Value = ErrorValue(d[6])
+			} else {
+				cell.Value = d[6] != 0
+			}
+		case RecFormula:
+			if len(d) < 14 {
+				continue
+			}
+			if d[12] != 0xFF || d[13] != 0xFF {
+				cell.Value = math.Float64frombits(binary.LittleEndian.Uint64(d[6:]))
+				break
+			}
+			switch d[6] {
+			case 0: // the string follows in a STRING record
+				formula = len(cells)
+				cell.Value = ""
+			case 1:
+				cell.Value = d[8] != 0
+			case 2:
+				cell.Value = ErrorValue(d[8])
+			case 3:
+				cell.Value = ""
+			}
+		case RecString:
+			if formula >= 0 {
+				if s, ok := wb.str(rec, 0); ok {
+					cells[formula].Value = s
+				}
+				formula = -1
+			}
+			continue
+		default:
+			continue
+		}
+		cells = append(cells, cell)
+	}
+}
+
+// str reads a string with a 16 bit count: an XLUnicodeString in BIFF8, or a byte string in BIFF5
+func (wb *Workbook) str(rec *Record, off int) (string, bool) {
+	if wb.Version == BIFF8 {
+		return newCursor(rec, off).unicode(false)
+	}
+	if off+2 > len(rec.Data) {
+		return "", false
+	}
+	l := int(binary.LittleEndian.Uint16(rec.Data[off:]))
+	if off+2+l > len(rec.Data) {
+		return "", false
+	}
+	return ole.Decode(rec.Data[off+2:off+2+l], wb.CodePage), true
+}
+
+// skip reads to the end of a substream
+func skip(rr *Reader) error {
+	for depth := 1; depth > 0; {
+		rec, err := rr.Next()
+		if err != nil {
+			return err
+		}
+		switch rec.Type {
+		case RecBOF:
+			depth++
+		case RecEOF:
+			depth--
+		}
+	}
+	return nil
+}
+
+// rk decodes an RK number: a 30 bit integer or the high bits of a float, optionally multiplied by 100
+func rk(v uint32) float64 {
+	var f float64
+	if v&2 != 0 {
+		f = float64(int32(v) >> 2)
+	} else {
+		f = math.Float64frombits(uint64(v&0xFFFFFFFC) << 32)
+	}
+	if v&1 != 0 {
+		f /= 100
+	}
+	return f
+}
@@ -0,0 +1,154 @@
+package xls
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/richardlehane/mscfb"
+	"github.com/richardlehane/mscfb/mscfbtest"
+)
+
+func TestWorkbook(t *testing.T) {
+	file, _ := os.Open("../test/test.xls")
+	defer file.Close()
+	doc, err := mscfb.New(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wb, err := New(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wb.Version != BIFF8 || len(wb.Sheets) != 3 || wb.Sheets[0].Name != "Test sheet 1" || wb.Sheets[2].Name != "Sheet3" {
+		t.Fatalf("bad workbook %+v", wb)
+	}
+	cells, err := wb.Cells(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range cells {
+		got = append(got, c.String())
+	}
+	if expect := []string{"Test1", "Lorem", "Ipsum", "Avocado", "1", "2", "3", "5", "4", "7"}; !reflect.DeepEqual(got, expect) {
+		t.Errorf("expecting %v, got %v", expect, got)
+	}
+}
+
+func record(typ uint16, data ...[]byte) []byte {
+	d := bytes.Join(data, nil)
+	b := make([]byte, 4, 4+len(d))
+	binary.LittleEndian.PutUint16(b, typ)
+	binary.LittleEndian.PutUint16(b[2:], uint16(len(d)))
+	return append(b, d...)
+}
+
+func le(v ...interface{}) []byte {
+	buf := &bytes.Buffer{}
+	for _, x := range v {
+		binary.Write(buf, binary.LittleEndian, x)
+	}
+	return buf.Bytes()
+}
+
+// book builds a workbook stream with a single sheet
+func book(name string, globals, sheet []byte) []byte {
+	bof := record(RecBOF, le(uint16(BIFF8), uint16(5), make([]byte, 12)))
+	bs := record(RecBoundSheet, le(uint32(0), uint16(0), uint8(len(name)), uint8(0)), []byte(name))
+	off := len(bof) + len(globals) + len(bs) + 4
+	binary.LittleEndian.PutUint32(bs[4:], uint32(off))
+	b := append(append(append(append([]byte{}, bof...), globals...), bs...), record(RecEOF)...)
+	b = append(b, record(RecBOF, le(uint16(BIFF8), uint16(0x10), make([]byte, 12)))...)
+	return append(append(b, sheet...), record(RecEOF)...)
+}
+
+func open(t *testing.T, name string, stream []byte) *Workbook {
+	b := mscfbtest.New()
+	b.Stream(name, stream)
+	buf, err := b.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := mscfb.New(bytes.NewReader(buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wb, err := New(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return wb
+}
+
+func TestBIFF8(t *testing.T) {
+	// SST: "héllo" compressed; "€uro" split across a CONTINUE, resuming uncompressed; a rich string split in its runs
+	sst := record(RecSST, le(uint32(3), uint32(3)),
+		le(uint16(5), uint8(0)), []byte("h\xe9llo"),
+		le(uint16(4), uint8(fHighByte), uint16(0x20AC), uint16('u')))
+	sst = append(sst, record(RecContinue, le(uint8(0)), []byte("ro"),
+		le(uint16(2), uint8(fRichSt), uint16(2)), []byte("ab"), make([]byte, 4))...)
+	sst = append(sst, record(RecContinue, make([]byte, 4))...)
+	formulaStr := le(uint16(3), uint16(0), uint16(0), uint8(0), uint8(0), uint8(0), uint8(0), uint16(0), uint16(0xFFFF), make([]byte, 6))
+	sheet := bytes.Join([][]byte{
+		record(RecLabelSST, le(uint16(0), uint16(0), uint16(15), uint32(1))),
+		record(RecLabelSST, le(uint16(0), uint16(1), uint16(15), uint32(2))),
+		record(RecRK, le(uint16(1), uint16(0), uint16(15), uint32(1234<<2|3))),
+		record(RecMulRK, le(uint16(1), uint16(1), uint16(15), uint32(7<<2|2), uint16(15), uint32(0x3FF00000)), le(uint16(2))),
+		record(RecNumber, le(uint16(2), uint16(0), uint16(15), math.Float64bits(-2.5))),
+		record(RecBoolErr, le(uint16(2), uint16(1), uint16(15), uint8(7), uint8(1))),
+		record(RecFormula, formulaStr),
+		record(RecString, le(uint16(3), uint8(0)), []byte("sum")),
+	}, nil)
+	wb := open(t, "Workbook", book("Data", sst, sheet))
+	if !reflect.DeepEqual(wb.SST, []string{"héllo", "€uro", "ab"}) {
+		t.Errorf("bad SST %q", wb.SST)
+	}
+	cells, err := wb.Cells(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []Cell{
+		{0, 0, "€uro"}, {0, 1, "ab"}, {1, 0, 12.34}, {1, 1, 7.0}, {1, 2, 1.0},
+		{2, 0, -2.5}, {2, 1, ErrorValue(7)}, {3, 0, "sum"},
+	}
+	if !reflect.DeepEqual(cells, expect) {
+		t.Errorf("expecting %v, got %v", expect, cells)
+	}
+	if cells[6].String() != "#DIV/0!" {
+		t.Errorf("bad error value %s", cells[6])
+	}
+}
+
+func TestBIFF5(t *testing.T) {
+	bof := record(RecBOF, le(uint16(BIFF5), uint16(5), make([]byte, 4)))
+	cp := record(RecCodePage, le(uint16(1252)))
+	bs := record(RecBoundSheet, le(uint32(0), uint16(0), uint8(6)), []byte("Blatt1"))
+	off := len(bof) + len(cp) + len(bs) + 4
+	binary.LittleEndian.PutUint32(bs[4:], uint32(off))
+	b := bytes.Join([][]byte{bof, cp, bs, record(RecEOF),
+		record(RecBOF, le(uint16(BIFF5), uint16(0x10), make([]byte, 4))),
+		record(RecLabel, le(uint16(0), uint16(0), uint16(15), uint16(7)), []byte("\x80 Preis")),
+		record(RecEOF)}, nil)
+	wb := open(t, "Book", b)
+	if wb.Version != BIFF5 || len(wb.Sheets) != 1 || wb.Sheets[0].Name != "Blatt1" {
+		t.Fatalf("bad workbook %+v", wb)
+	}
+	cells, err := wb.Cells(0)
+	if err != nil || len(cells) != 1 || cells[0].Value != "€ Preis" {
+		t.Errorf("bad cells %v %v", cells, err)
+	}
+}
+
+func TestEncrypted(t *testing.T) {
+	b := mscfbtest.New()
+	b.Stream("Workbook", book("Sheet1", record(RecFilePass, make([]byte, 6)), nil))
+	buf, _ := b.Bytes()
+	doc, _ := mscfb.New(bytes.NewReader(buf))
+	if _, err := New(doc); err != ErrEncrypted {
+		t.Errorf("expecting ErrEncrypted, got %v", err)
+	}
+}